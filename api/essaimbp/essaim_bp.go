@@ -14,6 +14,155 @@ var formatInt = strconv.FormatInt
 var jsonMarshal = json.Marshal
 var _ = bp.Useless
 
+const MAGIC uint16 = 21317
+
+const VERSION uint8 = 4
+
+const FLAG_AUTHENTICATED uint8 = 1
+
+type MessageType uint8 // 8bit
+
+const (
+	MESSAGE_TYPE_UNKNOWN MessageType = 0
+	MESSAGE_TYPE_PATTERN MessageType = 1
+	MESSAGE_TYPE_ANNOUNCE MessageType = 2
+	MESSAGE_TYPE_STATE MessageType = 3
+	MESSAGE_TYPE_STATE_REQUEST MessageType = 4
+	MESSAGE_TYPE_TAP MessageType = 5
+)
+
+// Returns string representation for enum MessageType.
+func (v MessageType) String() string {
+	switch v {
+	case MESSAGE_TYPE_UNKNOWN:
+		return "MESSAGE_TYPE_UNKNOWN"
+	case MESSAGE_TYPE_PATTERN:
+		return "MESSAGE_TYPE_PATTERN"
+	case MESSAGE_TYPE_ANNOUNCE:
+		return "MESSAGE_TYPE_ANNOUNCE"
+	case MESSAGE_TYPE_STATE:
+		return "MESSAGE_TYPE_STATE"
+	case MESSAGE_TYPE_STATE_REQUEST:
+		return "MESSAGE_TYPE_STATE_REQUEST"
+	case MESSAGE_TYPE_TAP:
+		return "MESSAGE_TYPE_TAP"
+	default:
+		return "MessageType(" + formatInt(int64(v), 10) + ")"
+	}
+}
+
+func (m *MessageType) BpProcessor() bp.Processor {
+	return bp.NewEnumProcessor(bp.NewUint(8))
+}
+
+type Header struct {
+	Magic uint16 `json:"magic"` // 16bit
+	Version uint8 `json:"version"` // 8bit
+	Type MessageType `json:"type"` // 8bit
+	Sequence uint32 `json:"sequence"` // 32bit
+	Length uint16 `json:"length"` // 16bit
+	Sender uint32 `json:"sender"` // 32bit
+	Timestamp uint64 `json:"timestamp"` // 64bit
+	Flags uint8 `json:"flags"` // 8bit
+}
+
+// Number of bytes to serialize struct Header
+const BYTES_LENGTH_HEADER uint32 = 23
+
+func (m *Header) Size() uint32 { return 23 }
+
+// Returns string representation for struct Header.
+func (m *Header) String() string {
+	v, _ := jsonMarshal(m)
+	return string(v)
+}
+
+// Encode struct Header to bytes buffer.
+func (m *Header) Encode() []byte {
+	ctx := bp.NewEncodeContext(int(m.Size()))
+	m.BpProcessor().Process(ctx, nil, m)
+	return ctx.Buffer()
+}
+
+func (m *Header) Decode(s []byte) {
+	ctx := bp.NewDecodeContext(s)
+	m.BpProcessor().Process(ctx, nil, m)
+}
+
+func (m *Header) BpProcessor() bp.Processor {
+	fieldDescriptors := []*bp.MessageFieldProcessor{
+		bp.NewMessageFieldProcessor(1, bp.NewUint(16)),
+		bp.NewMessageFieldProcessor(2, bp.NewUint(8)),
+		bp.NewMessageFieldProcessor(3, (new(MessageType)).BpProcessor()),
+		bp.NewMessageFieldProcessor(4, bp.NewUint(32)),
+		bp.NewMessageFieldProcessor(5, bp.NewUint(16)),
+		bp.NewMessageFieldProcessor(6, bp.NewUint(32)),
+		bp.NewMessageFieldProcessor(7, bp.NewUint(64)),
+		bp.NewMessageFieldProcessor(8, bp.NewUint(8)),
+	}
+	return bp.NewMessageProcessor(false, 184, fieldDescriptors)
+}
+
+func (m *Header) BpGetAccessor(di *bp.DataIndexer) bp.Accessor {
+	switch di.F() {
+	default:
+		return nil  // Won't reached
+	}
+}
+
+func (m *Header) BpSetByte(di *bp.DataIndexer, lshift int, b byte) {
+	switch di.F() {
+		case 1:
+			m.Magic |= (uint16(b) << lshift)
+		case 2:
+			m.Version |= (uint8(b) << lshift)
+		case 3:
+			m.Type |= (MessageType(b) << lshift)
+		case 4:
+			m.Sequence |= (uint32(b) << lshift)
+		case 5:
+			m.Length |= (uint16(b) << lshift)
+		case 6:
+			m.Sender |= (uint32(b) << lshift)
+		case 7:
+			m.Timestamp |= (uint64(b) << lshift)
+		case 8:
+			m.Flags |= (uint8(b) << lshift)
+		default:
+			return
+	}
+}
+
+func (m *Header) BpGetByte(di *bp.DataIndexer, rshift int) byte {
+	switch di.F() {
+		case 1:
+			return byte(m.Magic >> rshift)
+		case 2:
+			return byte(m.Version >> rshift)
+		case 3:
+			return byte(m.Type >> rshift)
+		case 4:
+			return byte(m.Sequence >> rshift)
+		case 5:
+			return byte(m.Length >> rshift)
+		case 6:
+			return byte(m.Sender >> rshift)
+		case 7:
+			return byte(m.Timestamp >> rshift)
+		case 8:
+			return byte(m.Flags >> rshift)
+		default:
+			return byte(0) // Won't reached
+	}
+}
+
+func (m *Header) BpProcessInt(di *bp.DataIndexer) {
+	switch di.F() {
+		default:
+			return
+	}
+}
+
 type RGBA struct {
 	R uint8 `json:"r"` // 8bit
 	G uint8 `json:"g"` // 8bit
@@ -99,14 +248,15 @@ func (m *RGBA) BpProcessInt(di *bp.DataIndexer) {
 }
 
 type Pattern struct {
-	Steps [16]RGBA `json:"steps"` // 512bit
+	Steps [64]RGBA `json:"steps"` // 2048bit
 	Channel uint64 `json:"channel"` // 64bit
+	Length uint8 `json:"length"` // 8bit
 }
 
 // Number of bytes to serialize struct Pattern
-const BYTES_LENGTH_PATTERN uint32 = 72
+const BYTES_LENGTH_PATTERN uint32 = 265
 
-func (m *Pattern) Size() uint32 { return 72 }
+func (m *Pattern) Size() uint32 { return 265 }
 
 // Returns string representation for struct Pattern.
 func (m *Pattern) String() string {
@@ -128,10 +278,11 @@ func (m *Pattern) Decode(s []byte) {
 
 func (m *Pattern) BpProcessor() bp.Processor {
 	fieldDescriptors := []*bp.MessageFieldProcessor{
-		bp.NewMessageFieldProcessor(1, bp.NewArray(false, 16, (&RGBA{}).BpProcessor())),
+		bp.NewMessageFieldProcessor(1, bp.NewArray(false, 64, (&RGBA{}).BpProcessor())),
 		bp.NewMessageFieldProcessor(2, bp.NewUint(64)),
+		bp.NewMessageFieldProcessor(3, bp.NewUint(8)),
 	}
-	return bp.NewMessageProcessor(false, 576, fieldDescriptors)
+	return bp.NewMessageProcessor(false, 2120, fieldDescriptors)
 }
 
 func (m *Pattern) BpGetAccessor(di *bp.DataIndexer) bp.Accessor {
@@ -147,6 +298,8 @@ func (m *Pattern) BpSetByte(di *bp.DataIndexer, lshift int, b byte) {
 	switch di.F() {
 		case 2:
 			m.Channel |= (uint64(b) << lshift)
+		case 3:
+			m.Length |= (uint8(b) << lshift)
 		default:
 			return
 	}
@@ -156,6 +309,8 @@ func (m *Pattern) BpGetByte(di *bp.DataIndexer, rshift int) byte {
 	switch di.F() {
 		case 2:
 			return byte(m.Channel >> rshift)
+		case 3:
+			return byte(m.Length >> rshift)
 		default:
 			return byte(0) // Won't reached
 	}
@@ -166,4 +321,316 @@ func (m *Pattern) BpProcessInt(di *bp.DataIndexer) {
 		default:
 			return
 	}
+}
+
+type NodeRole uint8 // 8bit
+
+const (
+	NODE_ROLE_UNKNOWN NodeRole = 0
+	NODE_ROLE_SCREEN NodeRole = 1
+	NODE_ROLE_DMX NodeRole = 2
+	NODE_ROLE_DEPTH_SERVER NodeRole = 3
+)
+
+// Returns string representation for enum NodeRole.
+func (v NodeRole) String() string {
+	switch v {
+	case NODE_ROLE_UNKNOWN:
+		return "NODE_ROLE_UNKNOWN"
+	case NODE_ROLE_SCREEN:
+		return "NODE_ROLE_SCREEN"
+	case NODE_ROLE_DMX:
+		return "NODE_ROLE_DMX"
+	case NODE_ROLE_DEPTH_SERVER:
+		return "NODE_ROLE_DEPTH_SERVER"
+	default:
+		return "NodeRole(" + formatInt(int64(v), 10) + ")"
+	}
+}
+
+func (m *NodeRole) BpProcessor() bp.Processor {
+	return bp.NewEnumProcessor(bp.NewUint(8))
+}
+
+type NodeHealth uint8 // 8bit
+
+const (
+	NODE_HEALTH_UNKNOWN NodeHealth = 0
+	NODE_HEALTH_OK NodeHealth = 1
+	NODE_HEALTH_DEGRADED NodeHealth = 2
+	NODE_HEALTH_FAILING NodeHealth = 3
+)
+
+// Returns string representation for enum NodeHealth.
+func (v NodeHealth) String() string {
+	switch v {
+	case NODE_HEALTH_UNKNOWN:
+		return "NODE_HEALTH_UNKNOWN"
+	case NODE_HEALTH_OK:
+		return "NODE_HEALTH_OK"
+	case NODE_HEALTH_DEGRADED:
+		return "NODE_HEALTH_DEGRADED"
+	case NODE_HEALTH_FAILING:
+		return "NODE_HEALTH_FAILING"
+	default:
+		return "NodeHealth(" + formatInt(int64(v), 10) + ")"
+	}
+}
+
+func (m *NodeHealth) BpProcessor() bp.Processor {
+	return bp.NewEnumProcessor(bp.NewUint(8))
+}
+
+type Announce struct {
+	Node uint32 `json:"node"` // 32bit
+	Role NodeRole `json:"role"` // 8bit
+	Channel uint64 `json:"channel"` // 64bit
+	Version uint8 `json:"version"` // 8bit
+	Uptime uint32 `json:"uptime"` // 32bit
+	Step uint64 `json:"step"` // 64bit
+	Health NodeHealth `json:"health"` // 8bit
+}
+
+// Number of bytes to serialize struct Announce
+const BYTES_LENGTH_ANNOUNCE uint32 = 27
+
+func (m *Announce) Size() uint32 { return 27 }
+
+// Returns string representation for struct Announce.
+func (m *Announce) String() string {
+	v, _ := jsonMarshal(m)
+	return string(v)
+}
+
+// Encode struct Announce to bytes buffer.
+func (m *Announce) Encode() []byte {
+	ctx := bp.NewEncodeContext(int(m.Size()))
+	m.BpProcessor().Process(ctx, nil, m)
+	return ctx.Buffer()
+}
+
+func (m *Announce) Decode(s []byte) {
+	ctx := bp.NewDecodeContext(s)
+	m.BpProcessor().Process(ctx, nil, m)
+}
+
+func (m *Announce) BpProcessor() bp.Processor {
+	fieldDescriptors := []*bp.MessageFieldProcessor{
+		bp.NewMessageFieldProcessor(1, bp.NewUint(32)),
+		bp.NewMessageFieldProcessor(2, (new(NodeRole)).BpProcessor()),
+		bp.NewMessageFieldProcessor(3, bp.NewUint(64)),
+		bp.NewMessageFieldProcessor(4, bp.NewUint(8)),
+		bp.NewMessageFieldProcessor(5, bp.NewUint(32)),
+		bp.NewMessageFieldProcessor(6, bp.NewUint(64)),
+		bp.NewMessageFieldProcessor(7, (new(NodeHealth)).BpProcessor()),
+	}
+	return bp.NewMessageProcessor(false, 216, fieldDescriptors)
+}
+
+func (m *Announce) BpGetAccessor(di *bp.DataIndexer) bp.Accessor {
+	switch di.F() {
+	default:
+		return nil  // Won't reached
+	}
+}
+
+func (m *Announce) BpSetByte(di *bp.DataIndexer, lshift int, b byte) {
+	switch di.F() {
+		case 1:
+			m.Node |= (uint32(b) << lshift)
+		case 2:
+			m.Role |= (NodeRole(b) << lshift)
+		case 3:
+			m.Channel |= (uint64(b) << lshift)
+		case 4:
+			m.Version |= (uint8(b) << lshift)
+		case 5:
+			m.Uptime |= (uint32(b) << lshift)
+		case 6:
+			m.Step |= (uint64(b) << lshift)
+		case 7:
+			m.Health |= (NodeHealth(b) << lshift)
+		default:
+			return
+	}
+}
+
+func (m *Announce) BpGetByte(di *bp.DataIndexer, rshift int) byte {
+	switch di.F() {
+		case 1:
+			return byte(m.Node >> rshift)
+		case 2:
+			return byte(m.Role >> rshift)
+		case 3:
+			return byte(m.Channel >> rshift)
+		case 4:
+			return byte(m.Version >> rshift)
+		case 5:
+			return byte(m.Uptime >> rshift)
+		case 6:
+			return byte(m.Step >> rshift)
+		case 7:
+			return byte(m.Health >> rshift)
+		default:
+			return byte(0) // Won't reached
+	}
+}
+
+func (m *Announce) BpProcessInt(di *bp.DataIndexer) {
+	switch di.F() {
+		default:
+			return
+	}
+}
+
+type ChannelState struct {
+	Pattern Pattern `json:"pattern"` // 2120bit
+	ActivePattern uint8 `json:"active_pattern"` // 8bit
+	Live uint8 `json:"live"` // 8bit
+}
+
+// Number of bytes to serialize struct ChannelState
+const BYTES_LENGTH_CHANNEL_STATE uint32 = 267
+
+func (m *ChannelState) Size() uint32 { return 267 }
+
+// Returns string representation for struct ChannelState.
+func (m *ChannelState) String() string {
+	v, _ := jsonMarshal(m)
+	return string(v)
+}
+
+// Encode struct ChannelState to bytes buffer.
+func (m *ChannelState) Encode() []byte {
+	ctx := bp.NewEncodeContext(int(m.Size()))
+	m.BpProcessor().Process(ctx, nil, m)
+	return ctx.Buffer()
+}
+
+func (m *ChannelState) Decode(s []byte) {
+	ctx := bp.NewDecodeContext(s)
+	m.BpProcessor().Process(ctx, nil, m)
+}
+
+func (m *ChannelState) BpProcessor() bp.Processor {
+	fieldDescriptors := []*bp.MessageFieldProcessor{
+		bp.NewMessageFieldProcessor(1, (&Pattern{}).BpProcessor()),
+		bp.NewMessageFieldProcessor(2, bp.NewUint(8)),
+		bp.NewMessageFieldProcessor(3, bp.NewUint(8)),
+	}
+	return bp.NewMessageProcessor(false, 2136, fieldDescriptors)
+}
+
+func (m *ChannelState) BpGetAccessor(di *bp.DataIndexer) bp.Accessor {
+	switch di.F() {
+	case 1:
+		return &(m.Pattern)
+	default:
+		return nil  // Won't reached
+	}
+}
+
+func (m *ChannelState) BpSetByte(di *bp.DataIndexer, lshift int, b byte) {
+	switch di.F() {
+		case 2:
+			m.ActivePattern |= (uint8(b) << lshift)
+		case 3:
+			m.Live |= (uint8(b) << lshift)
+		default:
+			return
+	}
+}
+
+func (m *ChannelState) BpGetByte(di *bp.DataIndexer, rshift int) byte {
+	switch di.F() {
+		case 2:
+			return byte(m.ActivePattern >> rshift)
+		case 3:
+			return byte(m.Live >> rshift)
+		default:
+			return byte(0) // Won't reached
+	}
+}
+
+func (m *ChannelState) BpProcessInt(di *bp.DataIndexer) {
+	switch di.F() {
+		default:
+			return
+	}
+}
+
+type State struct {
+	Channels [4]ChannelState `json:"channels"` // 8544bit
+	ActiveChannel uint8 `json:"active_channel"` // 8bit
+	Mode uint8 `json:"mode"` // 8bit
+}
+
+// Number of bytes to serialize struct State
+const BYTES_LENGTH_STATE uint32 = 1070
+
+func (m *State) Size() uint32 { return 1070 }
+
+// Returns string representation for struct State.
+func (m *State) String() string {
+	v, _ := jsonMarshal(m)
+	return string(v)
+}
+
+// Encode struct State to bytes buffer.
+func (m *State) Encode() []byte {
+	ctx := bp.NewEncodeContext(int(m.Size()))
+	m.BpProcessor().Process(ctx, nil, m)
+	return ctx.Buffer()
+}
+
+func (m *State) Decode(s []byte) {
+	ctx := bp.NewDecodeContext(s)
+	m.BpProcessor().Process(ctx, nil, m)
+}
+
+func (m *State) BpProcessor() bp.Processor {
+	fieldDescriptors := []*bp.MessageFieldProcessor{
+		bp.NewMessageFieldProcessor(1, bp.NewArray(false, 4, (&ChannelState{}).BpProcessor())),
+		bp.NewMessageFieldProcessor(2, bp.NewUint(8)),
+		bp.NewMessageFieldProcessor(3, bp.NewUint(8)),
+	}
+	return bp.NewMessageProcessor(false, 8560, fieldDescriptors)
+}
+
+func (m *State) BpGetAccessor(di *bp.DataIndexer) bp.Accessor {
+	switch di.F() {
+	case 1:
+		return &(m.Channels[di.I(0)])
+	default:
+		return nil  // Won't reached
+	}
+}
+
+func (m *State) BpSetByte(di *bp.DataIndexer, lshift int, b byte) {
+	switch di.F() {
+		case 2:
+			m.ActiveChannel |= (uint8(b) << lshift)
+		case 3:
+			m.Mode |= (uint8(b) << lshift)
+		default:
+			return
+	}
+}
+
+func (m *State) BpGetByte(di *bp.DataIndexer, rshift int) byte {
+	switch di.F() {
+		case 2:
+			return byte(m.ActiveChannel >> rshift)
+		case 3:
+			return byte(m.Mode >> rshift)
+		default:
+			return byte(0) // Won't reached
+	}
+}
+
+func (m *State) BpProcessInt(di *bp.DataIndexer) {
+	switch di.F() {
+		default:
+			return
+	}
 }
\ No newline at end of file
@@ -0,0 +1,119 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	// magic starts every capture file.
+	magic   = "ESSAIMCAP"
+	version = uint16(1)
+
+	recordHeaderSize = 8 + 8 + 2
+)
+
+var (
+	ErrBadMagic           = errors.New("file is not an essaim capture")
+	ErrUnsupportedVersion = errors.New("unsupported capture version")
+	ErrTooLarge           = errors.New("captured frame is too large")
+)
+
+// Record is a frame captured on the network, along with the moment it was
+// received.
+type Record struct {
+	// Elapsed is the time elapsed since the start of the capture.
+	Elapsed time.Duration
+	// Step is the step of the clock followed during the capture.
+	Step int64
+	// Frame holds the raw frame, as sent on the network.
+	Frame []byte
+}
+
+// Writer writes records to a capture file, one write per record so that the
+// file stays readable if the capture is interrupted.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	header := binary.BigEndian.AppendUint16([]byte(magic), version)
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("could not write capture header: %w", err)
+	}
+
+	return &Writer{w: w}, nil
+}
+
+func (w *Writer) Write(r Record) error {
+	if len(r.Frame) > protocol.MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrTooLarge, len(r.Frame))
+	}
+
+	b := make([]byte, 0, recordHeaderSize+len(r.Frame))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Elapsed))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Step))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(r.Frame)))
+	b = append(b, r.Frame...)
+
+	if _, err := w.w.Write(b); err != nil {
+		return fmt.Errorf("could not write record: %w", err)
+	}
+
+	return nil
+}
+
+// Reader reads the records of a capture file, in the order they were
+// captured.
+type Reader struct {
+	r io.Reader
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	header := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("could not read capture header: %w", err)
+	}
+
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+
+	if v := binary.BigEndian.Uint16(header[len(magic):]); v != version {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrUnsupportedVersion, v, version)
+	}
+
+	return &Reader{r: r}, nil
+}
+
+// Read returns the next record, or io.EOF once every record was read.
+func (r *Reader) Read() (Record, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, fmt.Errorf("could not read record: %w", err)
+		}
+		return Record{}, err
+	}
+
+	size := int(binary.BigEndian.Uint16(header[16:]))
+	if size > protocol.MaxFrameSize {
+		return Record{}, fmt.Errorf("%w: %d bytes", ErrTooLarge, size)
+	}
+
+	record := Record{
+		Elapsed: time.Duration(binary.BigEndian.Uint64(header[0:])),
+		Step:    int64(binary.BigEndian.Uint64(header[8:])),
+		Frame:   make([]byte, size),
+	}
+	if _, err := io.ReadFull(r.r, record.Frame); err != nil {
+		return Record{}, fmt.Errorf("could not read record: %w", err)
+	}
+
+	return record, nil
+}
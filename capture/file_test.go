@@ -0,0 +1,132 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"essaim.dev/essaim/protocol"
+)
+
+func recordsEqual(a, b Record) bool {
+	return a.Elapsed == b.Elapsed && a.Step == b.Step && bytes.Equal(a.Frame, b.Frame)
+}
+
+func writeCapture(t *testing.T, records ...Record) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	w, err := NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	records := []Record{
+		{Elapsed: 0, Step: -4, Frame: []byte("first frame")},
+		{Elapsed: 1500 * time.Millisecond, Step: 12, Frame: nil},
+		{Elapsed: time.Hour, Step: 1 << 40, Frame: make([]byte, protocol.MaxFrameSize)},
+	}
+
+	r, err := NewReader(bytes.NewReader(writeCapture(t, records...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Record
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, record)
+	}
+
+	if !slices.EqualFunc(got, records, recordsEqual) {
+		t.Fatalf("got records %+v, want %+v", got, records)
+	}
+}
+
+func TestCaptureTruncated(t *testing.T) {
+	b := writeCapture(t, Record{Step: 1, Frame: []byte("first")}, Record{Step: 2, Frame: []byte("second")})
+
+	// Captures interrupted in the middle of a record, whether in its header or
+	// in its frame, keep the records before it.
+	for _, cut := range []int{5, recordHeaderSize + 3} {
+		r, err := NewReader(bytes.NewReader(b[:len(b)-cut]))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if record, err := r.Read(); err != nil || record.Step != 1 {
+			t.Fatalf("got record %+v and error %v, want step 1", record, err)
+		}
+		if _, err := r.Read(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestCaptureHeader(t *testing.T) {
+	b := writeCapture(t)
+
+	if _, err := NewReader(bytes.NewReader([]byte("NOTESSAIM\x00\x01"))); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("got error %v, want %v", err, ErrBadMagic)
+	}
+
+	future := binary.BigEndian.AppendUint16([]byte(magic), version+1)
+	if _, err := NewReader(bytes.NewReader(future)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("got error %v, want %v", err, ErrUnsupportedVersion)
+	}
+
+	if _, err := NewReader(bytes.NewReader(b[:len(b)-1])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// A capture without any record is empty rather than invalid.
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Fatalf("got error %v, want %v", err, io.EOF)
+	}
+}
+
+func TestCaptureTooLarge(t *testing.T) {
+	w, err := NewWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Record{Frame: make([]byte, protocol.MaxFrameSize+1)}); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got error %v, want %v", err, ErrTooLarge)
+	}
+
+	// A corrupted size is not trusted to allocate the frame.
+	b := writeCapture(t, Record{Frame: []byte("frame")})
+	binary.BigEndian.PutUint16(b[len(magic)+2+16:], protocol.MaxFrameSize+1)
+
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got error %v, want %v", err, ErrTooLarge)
+	}
+}
@@ -0,0 +1,201 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	// SyncTime replays the records at the pace they were captured.
+	SyncTime = "time"
+	// SyncStep replays the records on the steps of a clock, at the same
+	// distance from the first record as during the capture.
+	SyncStep = "step"
+)
+
+const channelsCount = len(essaimbp.State{}.Channels)
+
+// Player replays the pattern updates of captures to the nodes, as if they
+// were sent by the controller.
+type Player struct {
+	sender *protocol.Sender
+
+	sync   string
+	events <-chan clock.Event
+
+	// patterns are the patterns played on each channel, updated from the
+	// records replayed so far.
+	patterns [channelsCount]*pattern.ColorPattern
+
+	onPlay func(Record, protocol.Frame)
+	onSkip func(Record, error)
+}
+
+// NewPlayer returns a player writing the records replayed to the given
+// connection, one frame per write. The clock is only used when syncing on its
+// steps.
+func NewPlayer(w io.Writer, sync string, clk clock.Clock, auth protocol.Auth) (*Player, error) {
+	p := &Player{
+		sender: protocol.NewSender(w, auth),
+		sync:   sync,
+	}
+
+	switch sync {
+	case SyncTime:
+	case SyncStep:
+		if clk == nil {
+			return nil, errors.New("syncing on steps requires a clock")
+		}
+		p.events = clk.Events()
+	default:
+		return nil, fmt.Errorf("unknown sync mode: %q", sync)
+	}
+	for ch := range p.patterns {
+		p.patterns[ch] = pattern.NewColorPattern(pattern.MaxSteps)
+	}
+
+	return p, nil
+}
+
+// SetOnPlayFunc sets the function called with every record replayed. It must
+// be set before Play.
+func (p *Player) SetOnPlayFunc(f func(Record, protocol.Frame)) {
+	p.onPlay = f
+}
+
+// SetOnSkipFunc sets the function called with every record which could not
+// be replayed, along with the reason. It must be set before Play.
+func (p *Player) SetOnSkipFunc(f func(Record, error)) {
+	p.onSkip = f
+}
+
+// Pattern returns the pattern played on the given channel, as of the last
+// record replayed.
+func (p *Player) Pattern(ch uint64) *pattern.ColorPattern {
+	if ch >= uint64(len(p.patterns)) {
+		return nil
+	}
+
+	return p.patterns[ch]
+}
+
+// Summary describes the length of the pattern played on each channel.
+func (p *Player) Summary() string {
+	lengths := make([]string, len(p.patterns))
+	for ch, pat := range p.patterns {
+		lengths[ch] = fmt.Sprintf("%d:%d", ch, pat.Len())
+	}
+
+	return strings.Join(lengths, " ")
+}
+
+// Play replays every record of a capture, until its end or until the context
+// is done. Only the patterns and states are sent again, under the sender of
+// the player, since the messages of the nodes are of no use to the other
+// nodes. Records which cannot be replayed are skipped.
+func (p *Player) Play(ctx context.Context, reader *Reader) error {
+	var first Record
+	var started time.Time
+	var startStep, currentStep int64
+
+	for idx := 0; ; idx++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if idx == 0 {
+			first = record
+			started = time.Now()
+
+			if p.events != nil {
+				select {
+				case event, ok := <-p.events:
+					if !ok {
+						return clock.ErrClosed
+					}
+					startStep, currentStep = event.Step, event.Step
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		switch p.sync {
+		case SyncTime:
+			select {
+			case <-time.After(time.Until(started.Add(record.Elapsed - first.Elapsed))):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+		case SyncStep:
+			for target := startStep + record.Step - first.Step; currentStep < target; {
+				select {
+				case event, ok := <-p.events:
+					if !ok {
+						return clock.ErrClosed
+					}
+					currentStep = event.Step
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		if err := p.play(record); err != nil && p.onSkip != nil {
+			p.onSkip(record, err)
+		}
+	}
+}
+
+func (p *Player) play(record Record) error {
+	frame, err := protocol.Decode(record.Frame)
+	if errors.Is(err, protocol.ErrUnknownType) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not decode frame: %w", err)
+	}
+
+	switch frame.Header.Type {
+	case essaimbp.MESSAGE_TYPE_PATTERN:
+		for ch, pat := range p.patterns {
+			if err := pat.Decode(frame.Payload, uint64(ch)); err != nil {
+				return fmt.Errorf("could not decode pattern: %w", err)
+			}
+		}
+
+	case essaimbp.MESSAGE_TYPE_STATE:
+		for ch, pat := range p.patterns {
+			if err := pat.DecodeState(frame.Payload, uint64(ch)); err != nil {
+				return fmt.Errorf("could not decode state: %w", err)
+			}
+		}
+
+	default:
+		return nil
+	}
+
+	if err := p.sender.Send(frame.Header.Type, frame.Payload); err != nil {
+		return fmt.Errorf("could not send frame: %w", err)
+	}
+
+	if p.onPlay != nil {
+		p.onPlay(record, frame)
+	}
+
+	return nil
+}
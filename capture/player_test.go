@@ -0,0 +1,74 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/protocol"
+)
+
+// frames keeps every frame written to it.
+type frames [][]byte
+
+func (f *frames) Write(b []byte) (int, error) {
+	*f = append(*f, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func patternFrame(ch uint64, col color.RGBA) []byte {
+	p := pattern.NewColorPattern(4)
+	p.SetColorAt(0, col)
+
+	return protocol.Encode(essaimbp.Header{Type: essaimbp.MESSAGE_TYPE_PATTERN}, p.Encode(ch))
+}
+
+func TestPlayerSkip(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	b := writeCapture(t,
+		Record{Frame: patternFrame(1, red)},
+		Record{Frame: []byte("not a frame")},
+		// The messages of the nodes are not replayed.
+		Record{Frame: protocol.Encode(essaimbp.Header{Type: essaimbp.MESSAGE_TYPE_STATE_REQUEST}, nil)},
+	)
+
+	var sent frames
+	p, err := NewPlayer(&sent, SyncTime, nil, protocol.Auth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var played, skipped []Record
+	p.SetOnPlayFunc(func(record Record, frame protocol.Frame) {
+		played = append(played, record)
+	})
+	p.SetOnSkipFunc(func(record Record, err error) {
+		if !errors.Is(err, protocol.ErrTooShort) && !errors.Is(err, protocol.ErrBadMagic) {
+			t.Errorf("got error %v for a record skipped", err)
+		}
+		skipped = append(skipped, record)
+	})
+
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 1 || len(played) != 1 {
+		t.Fatalf("got %d frames sent and %d records played, want 1", len(sent), len(played))
+	}
+	if len(skipped) != 1 || string(skipped[0].Frame) != "not a frame" {
+		t.Fatalf("got records skipped %+v, want the invalid one", skipped)
+	}
+
+	if col, _ := p.Pattern(1).ColorAt(0); col != red {
+		t.Fatalf("got colour %v on channel 1, want %v", col, red)
+	}
+}
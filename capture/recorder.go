@@ -0,0 +1,115 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
+)
+
+// Recorder captures every frame received on a connection, stamped with the
+// time and the step of the clock at which it was received.
+type Recorder struct {
+	clock  clock.Clock
+	conn   transport.Conn
+	writer *Writer
+
+	currentStep atomic.Int64
+
+	onRecord   func(Record, protocol.Frame)
+	onDiscard  func(err error)
+	onRecordMu sync.RWMutex
+}
+
+func NewRecorder(clock clock.Clock, conn transport.Conn, writer *Writer) *Recorder {
+	return &Recorder{
+		clock:  clock,
+		conn:   conn,
+		writer: writer,
+	}
+}
+
+// SetOnRecordFunc sets the function called with every record written, along
+// with its decoded frame.
+func (r *Recorder) SetOnRecordFunc(f func(Record, protocol.Frame)) {
+	r.onRecordMu.Lock()
+	defer r.onRecordMu.Unlock()
+
+	r.onRecord = f
+}
+
+// SetOnDiscardFunc sets the function called with the reason of every frame
+// left out of the capture.
+func (r *Recorder) SetOnDiscardFunc(f func(err error)) {
+	r.onRecordMu.Lock()
+	defer r.onRecordMu.Unlock()
+
+	r.onDiscard = f
+}
+
+// Run records frames until the context is done or the connection fails.
+// Frames which cannot be decoded are left out of the capture, while those of
+// unknown types are kept.
+func (r *Recorder) Run(ctx context.Context) error {
+	go r.followClock(ctx)
+
+	started := time.Now()
+
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		n, _, err := r.conn.ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error while reading frames: %w", err)
+		}
+
+		frame, err := protocol.Decode(b[:n])
+		if err != nil && !errors.Is(err, protocol.ErrUnknownType) {
+			r.onRecordMu.RLock()
+			if r.onDiscard != nil {
+				r.onDiscard(err)
+			}
+			r.onRecordMu.RUnlock()
+			continue
+		}
+
+		record := Record{
+			Elapsed: time.Since(started),
+			Step:    r.currentStep.Load(),
+			Frame:   append([]byte(nil), b[:n]...),
+		}
+		if err := r.writer.Write(record); err != nil {
+			return err
+		}
+
+		r.onRecordMu.RLock()
+		if r.onRecord != nil {
+			r.onRecord(record, frame)
+		}
+		r.onRecordMu.RUnlock()
+	}
+}
+
+func (r *Recorder) followClock(ctx context.Context) {
+	events := r.clock.Events()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			r.currentStep.Store(event.Step)
+		}
+	}
+}
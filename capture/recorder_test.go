@@ -0,0 +1,82 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"net/netip"
+	"testing"
+
+	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/protocol"
+)
+
+// fakeConn delivers the given frames, then fails.
+type fakeConn struct {
+	incoming [][]byte
+}
+
+func (c *fakeConn) ReadFrom(b []byte) (int, netip.AddrPort, error) {
+	if len(c.incoming) == 0 {
+		return 0, netip.AddrPort{}, io.ErrClosedPipe
+	}
+
+	n := copy(b, c.incoming[0])
+	c.incoming = c.incoming[1:]
+
+	return n, netip.AddrPort{}, nil
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func TestRecorderDiscard(t *testing.T) {
+	valid := patternFrame(2, color.RGBA{255, 255, 255, 255})
+	conn := &fakeConn{incoming: [][]byte{[]byte("not a frame"), valid}}
+
+	var b bytes.Buffer
+	w, err := NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk := clock.NewVirtualClock(120)
+	defer clk.Close()
+
+	r := NewRecorder(clk, conn, w)
+
+	var discarded []error
+	r.SetOnDiscardFunc(func(err error) {
+		discarded = append(discarded, err)
+	})
+
+	if err := r.Run(context.Background()); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("got error %v, want %v", err, io.ErrClosedPipe)
+	}
+
+	if len(discarded) != 1 {
+		t.Fatalf("got %d frames discarded, want 1", len(discarded))
+	}
+	if !errors.Is(discarded[0], protocol.ErrTooShort) && !errors.Is(discarded[0], protocol.ErrBadMagic) {
+		t.Fatalf("got error %v for the frame discarded", discarded[0])
+	}
+
+	// Only the valid frame is captured.
+	reader, err := NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, err := reader.Read(); err != nil || !bytes.Equal(record.Frame, valid) {
+		t.Fatalf("got record %+v and error %v, want the valid frame", record, err)
+	}
+	if _, err := reader.Read(); !errors.Is(err, io.EOF) {
+		t.Fatalf("got error %v, want %v", err, io.EOF)
+	}
+}
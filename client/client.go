@@ -2,18 +2,21 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	"net"
-	"net/netip"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"essaim.dev/essaim/api/essaimbp"
 	"essaim.dev/essaim/clock"
 	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/presence"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 	"golang.org/x/exp/shiny/screen"
 	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/lifecycle"
@@ -26,12 +29,17 @@ const (
 
 type Client struct {
 	clock clock.Clock
-	conn  *net.UDPConn
+	conn  transport.Conn
 
 	pattern   *pattern.ColorPattern
 	patternMu sync.RWMutex
+	receiver  *protocol.Receiver
 
-	currentStep atomic.Int32
+	announcer *presence.Announcer
+	// lastStats are the reception stats at the previous announce.
+	lastStats protocol.Stats
+
+	currentStep atomic.Int64
 
 	stopped      chan error
 	refreshImage chan *image.RGBA
@@ -44,37 +52,71 @@ type Client struct {
 func New(
 	clock clock.Clock,
 	stepCount int,
-	addr netip.AddrPort,
+	conn transport.Conn,
 	renderFunc func(color.Color) *image.RGBA,
 	channel uint64,
-	iface *net.Interface,
+	auth protocol.Auth,
 ) (*Client, error) {
-	conn, err := net.ListenMulticastUDP("udp4", iface, net.UDPAddrFromAddrPort(addr))
-	if err != nil {
-		return nil, fmt.Errorf("could not listen on multicast address: %w", err)
-	}
-	conn.SetReadBuffer(512)
+	announcer := presence.NewAnnouncer(conn, essaimbp.NODE_ROLE_SCREEN, channel, auth)
 
-	return &Client{
+	c := &Client{
 		clock:        clock,
 		conn:         conn,
 		pattern:      pattern.NewColorPattern(stepCount),
+		receiver:     protocol.NewReceiver(auth),
+		announcer:    announcer,
 		stopped:      make(chan error, 1),
 		refreshImage: make(chan *image.RGBA),
 		renderFunc:   renderFunc,
 		channel:      channel,
-	}, nil
+	}
+	announcer.SetStatusFunc(c.status)
+
+	return c, nil
 }
 
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+func (c *Client) requestState() {
+	if err := c.announcer.RequestState(); err != nil {
+		fmt.Printf("could not request state: %s\n", err)
+	}
+}
+
+func (c *Client) status() presence.Status {
+	stats := c.receiver.Stats()
+	health := presence.ReceptionHealth(c.lastStats, stats)
+	c.lastStats = stats
+
+	return presence.Status{
+		Step:   c.currentStep.Load(),
+		Health: health,
+	}
+}
+
+// Stats returns the counters of the pattern updates received, including the
+// lost and dropped ones.
+func (c *Client) Stats() protocol.Stats {
+	return c.receiver.Stats()
+}
+
 func (c *Client) Run(ctx context.Context) error {
+	go c.announcer.Run(ctx)
+
+	// Connections which may be reestablished request the state every time
+	// they are, as frames may have been missed in the meantime.
+	if connector, ok := c.conn.(transport.Connector); ok {
+		connector.SetOnConnectFunc(c.requestState)
+	} else {
+		c.requestState()
+	}
+
 	connStopped := make(chan error, 1)
 	go c.consumeConn(connStopped)
 
-	ticks := c.clock.Tick()
+	events := c.clock.Events()
 
 	refresh := time.NewTicker(refreshRate)
 
@@ -89,11 +131,14 @@ func (c *Client) Run(ctx context.Context) error {
 			}
 			return nil
 
-		case tick := <-ticks:
-			c.currentStep.Store(int32(tick % 16))
+		case event, ok := <-events:
+			if !ok {
+				return clock.ErrClosed
+			}
+			c.currentStep.Store(event.Step)
 
 		case <-refresh.C:
-			col, _ := c.pattern.ColorAt(int(c.currentStep.Load()))
+			col, _ := c.pattern.ColorAt(c.pattern.StepAt(c.currentStep.Load()))
 			c.refreshImage <- c.renderFunc(col)
 
 		case <-ctx.Done():
@@ -103,20 +148,49 @@ func (c *Client) Run(ctx context.Context) error {
 }
 
 func (c *Client) consumeConn(stopped chan error) {
-	b := make([]byte, 512)
+	b := make([]byte, protocol.MaxFrameSize)
 
 	for {
-		n, err := c.conn.Read(b)
+		n, _, err := c.conn.ReadFrom(b)
 		if err != nil {
-			stopped <- fmt.Errorf("error while reading from udp: %w", err)
+			stopped <- fmt.Errorf("error while reading frames: %w", err)
 			return
 		}
 
+		if err := c.handleFrame(b[:n]); err != nil {
+			fmt.Printf("discarding packet: %s\n", err)
+		}
+	}
+}
+
+func (c *Client) handleFrame(b []byte) error {
+	frame, err := c.receiver.Receive(b)
+	if errors.Is(err, protocol.ErrUnknownType) || errors.Is(err, protocol.ErrStale) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not decode frame: %w", err)
+	}
+
+	switch frame.Header.Type {
+	case essaimbp.MESSAGE_TYPE_PATTERN:
 		c.patternMu.Lock()
-		c.pattern.Decode(b[:n], c.channel)
-		c.patternMu.Unlock()
+		defer c.patternMu.Unlock()
+
+		if err := c.pattern.Decode(frame.Payload, c.channel); err != nil {
+			return fmt.Errorf("could not decode pattern: %w", err)
+		}
 
+	case essaimbp.MESSAGE_TYPE_STATE:
+		c.patternMu.Lock()
+		defer c.patternMu.Unlock()
+
+		if err := c.pattern.DecodeState(frame.Payload, c.channel); err != nil {
+			return fmt.Errorf("could not decode state: %w", err)
+		}
 	}
+
+	return nil
 }
 
 func (c *Client) Display(s screen.Screen) {
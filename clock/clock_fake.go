@@ -2,28 +2,42 @@ package clock
 
 import "time"
 
+const (
+	fakeRefreshRate = time.Duration(time.Millisecond * 10)
+)
+
+// FakeClock is a virtual clock driven by the wall clock, used to rehearse
+// without any music source.
 type FakeClock struct {
+	*VirtualClock
 }
 
-func NewKafeClock() *FakeClock {
-	return &FakeClock{}
+func NewFakeClock(bpm float64) *FakeClock {
+	return &FakeClock{
+		VirtualClock: NewVirtualClock(bpm),
+	}
 }
 
-func (c *FakeClock) Close() error {
-	return nil
+func (c *FakeClock) Start() {
+	go c.run()
 }
 
 func (c *FakeClock) Tick() <-chan int64 {
-	ch := make(chan int64)
-
-	go func() {
-		x := int64(0)
-		t := time.NewTicker(time.Millisecond * 200)
-		for range t.C {
-			ch <- x
-			x++
-		}
-	}()
+	return Tick(c)
+}
 
-	return ch
+func (c *FakeClock) run() {
+	t := time.NewTicker(fakeRefreshRate)
+	defer t.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-t.C:
+			c.AdvanceTime(now.Sub(last))
+			last = now
+		}
+	}
 }
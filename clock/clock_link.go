@@ -7,11 +7,35 @@ import (
 	"essaim.dev/al"
 )
 
+const (
+	linkQuantum = defaultQuantum
+
+	linkPollRate = time.Duration(time.Millisecond * 10)
+
+	// linkTempoEpsilon is the smallest tempo variation reported as a change.
+	linkTempoEpsilon = 0.01
+)
+
 type LinkClock struct {
 	link *al.Link
 
-	bpm   float64
-	bpmMu sync.RWMutex
+	bpm     float64
+	peers   uint64
+	playing bool
+	stateMu sync.RWMutex
+
+	onTempo   func(bpm float64)
+	onPeers   func(peers uint64)
+	onPlaying func(playing bool)
+	onMu      sync.RWMutex
+
+	// running tracks the goroutines and calls using the link, which must stop
+	// before it is freed. None is started once closed.
+	running   sync.WaitGroup
+	runningMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 func NewLinkClock(bpm float64) *LinkClock {
@@ -20,34 +44,144 @@ func NewLinkClock(bpm float64) *LinkClock {
 	return &LinkClock{
 		bpm:  bpm,
 		link: l,
+		done: make(chan struct{}),
 	}
 }
 
 func (c *LinkClock) Start() {
+	if !c.acquire() {
+		return
+	}
+	defer c.running.Done()
+
+	c.link.EnableStartStopSync(true)
 	c.link.Enable(true)
+
+	c.running.Add(1)
+	go c.monitor()
 }
 
 func (c *LinkClock) Close() error {
-	c.link.Enable(false)
-	c.link.Close()
+	c.closeOnce.Do(func() {
+		c.runningMu.Lock()
+		close(c.done)
+		c.runningMu.Unlock()
+
+		c.running.Wait()
+
+		c.link.Enable(false)
+		c.link.Close()
+	})
+
 	return nil
 }
 
-func (c *LinkClock) Tick() <-chan int64 {
-	ch := make(chan int64)
+func (c *LinkClock) Events() <-chan Event {
+	ch := make(chan Event)
+
+	if !c.acquire() {
+		close(ch)
+		return ch
+	}
 	go c.produce(ch)
 
 	return ch
 }
 
+// acquire registers a use of the link, which is not freed until it is done.
+// It fails once the clock is closed.
+func (c *LinkClock) acquire() bool {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+
+	if closed(c.done) {
+		return false
+	}
+	c.running.Add(1)
+
+	return true
+}
+
+func (c *LinkClock) Tick() <-chan int64 {
+	return Tick(c)
+}
+
 func (c *LinkClock) BPM() float64 {
-	c.bpmMu.RLock()
-	defer c.bpmMu.RUnlock()
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
 
 	return c.bpm
 }
 
-func (c *LinkClock) produce(ch chan int64) {
+func (c *LinkClock) Peers() uint64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.peers
+}
+
+func (c *LinkClock) Playing() bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.playing
+}
+
+// SetTempo proposes a new tempo to the Link session, which is then adopted by
+// every peer. It does nothing once the clock is closed.
+func (c *LinkClock) SetTempo(bpm float64) {
+	if !c.acquire() {
+		return
+	}
+	defer c.running.Done()
+
+	state := al.NewSessionState()
+
+	c.link.CaptureAppSessionState(state)
+	state.SetTempo(bpm, c.link.Clock())
+	c.link.CommitAppSessionState(state)
+}
+
+// SetPlaying starts or stops the transport of the Link session. It does nothing
+// once the clock is closed.
+func (c *LinkClock) SetPlaying(playing bool) {
+	if !c.acquire() {
+		return
+	}
+	defer c.running.Done()
+
+	state := al.NewSessionState()
+
+	c.link.CaptureAppSessionState(state)
+	state.SetIsPlaying(playing, c.link.Clock())
+	c.link.CommitAppSessionState(state)
+}
+
+func (c *LinkClock) SetOnTempoFunc(f func(bpm float64)) {
+	c.onMu.Lock()
+	defer c.onMu.Unlock()
+
+	c.onTempo = f
+}
+
+func (c *LinkClock) SetOnPeersFunc(f func(peers uint64)) {
+	c.onMu.Lock()
+	defer c.onMu.Unlock()
+
+	c.onPeers = f
+}
+
+func (c *LinkClock) SetOnPlayingFunc(f func(playing bool)) {
+	c.onMu.Lock()
+	defer c.onMu.Unlock()
+
+	c.onPlaying = f
+}
+
+func (c *LinkClock) produce(ch chan Event) {
+	defer c.running.Done()
+	defer close(ch)
+
 	state := al.NewSessionState()
 
 	lastStep := int64(0)
@@ -55,22 +189,72 @@ func (c *LinkClock) produce(ch chan int64) {
 	for {
 		c.link.CaptureAppSessionState(state)
 
-		beat := state.BeatAtTime(c.link.Clock(), 4)
-		step := int64(beat * 4)
+		beat := state.BeatAtTime(c.link.Clock(), linkQuantum)
+		event := newEvent(beat, state.Tempo(), linkQuantum, state.IsPlaying())
 
-		if step > lastStep {
-			ch <- step
+		if event.Step > lastStep {
+			select {
+			case ch <- event:
+			case <-c.done:
+				return
+			}
 		}
 
-		lastStep = step
+		lastStep = event.Step
 
-		time.Sleep(time.Millisecond * 10)
+		select {
+		case <-time.After(linkPollRate):
+		case <-c.done:
+			return
+		}
 	}
 }
 
-// func (c *LinkClock) tempoCallback(tempo float64) {
-// 	c.bpmMu.Lock()
-// 	defer c.bpmMu.Unlock()
+// monitor follows the tempo, peers and transport of the session and reports
+// their changes to the registered callbacks.
+func (c *LinkClock) monitor() {
+	defer c.running.Done()
+
+	state := al.NewSessionState()
+
+	t := time.NewTicker(linkPollRate)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+		}
+
+		c.link.CaptureAppSessionState(state)
+		bpm := state.Tempo()
+		playing := state.IsPlaying()
+		peers := c.link.NumPeers()
 
-// 	c.bpm = tempo
-// }
+		c.stateMu.Lock()
+		tempoChanged := bpm-c.bpm > linkTempoEpsilon || c.bpm-bpm > linkTempoEpsilon
+		peersChanged := peers != c.peers
+		playingChanged := playing != c.playing
+		if tempoChanged {
+			c.bpm = bpm
+		}
+		c.peers = peers
+		c.playing = playing
+		c.stateMu.Unlock()
+
+		c.onMu.RLock()
+		onTempo, onPeers, onPlaying := c.onTempo, c.onPeers, c.onPlaying
+		c.onMu.RUnlock()
+
+		if tempoChanged && onTempo != nil {
+			onTempo(bpm)
+		}
+		if peersChanged && onPeers != nil {
+			onPeers(peers)
+		}
+		if playingChanged && onPlaying != nil {
+			onPlaying(playing)
+		}
+	}
+}
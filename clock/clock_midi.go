@@ -0,0 +1,259 @@
+package clock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	midiTimingClock  = 0xF8
+	midiStart        = 0xFA
+	midiContinue     = 0xFB
+	midiStop         = 0xFC
+	midiSongPosition = 0xF2
+	midiSysExEnd     = 0xF7
+
+	midiPulsesPerQuarter = 24
+	midiPulsesPerStep    = midiPulsesPerQuarter / 4
+
+	// midiTempoSmoothing is the weight given to the latest pulse interval when
+	// estimating the tempo.
+	midiTempoSmoothing = 0.1
+
+	midiTickBufferSize = 16
+)
+
+// MIDIClock follows a MIDI beat clock (24 pulses per quarter note) read from a
+// raw MIDI byte stream, such as an ALSA rawmidi device or a virtual port.
+type MIDIClock struct {
+	r io.Reader
+
+	subscribers   []chan Event
+	subscribersMu sync.RWMutex
+
+	// pulse is the number of clock pulses elapsed since the song position 0.
+	pulse   int64
+	playing bool
+
+	lastPulseAt   time.Time
+	pulseInterval time.Duration
+	bpm           float64
+	stateMu       sync.RWMutex
+
+	// status and data hold the running message being parsed.
+	status byte
+	data   []byte
+
+	onError   func(err error)
+	onErrorMu sync.RWMutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewMIDIClock(r io.Reader) *MIDIClock {
+	return &MIDIClock{
+		r:    r,
+		data: make([]byte, 0, 2),
+		done: make(chan struct{}),
+	}
+}
+
+func OpenMIDIClock(path string) (*MIDIClock, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open midi device: %w", err)
+	}
+
+	return NewMIDIClock(f), nil
+}
+
+func (c *MIDIClock) Start() {
+	go c.consume()
+}
+
+func (c *MIDIClock) Close() error {
+	c.closeOnce.Do(func() {
+		c.subscribersMu.Lock()
+		defer c.subscribersMu.Unlock()
+
+		close(c.done)
+		closeSubscribers(c.subscribers)
+		c.subscribers = nil
+	})
+
+	if closer, ok := c.r.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+func (c *MIDIClock) Events() <-chan Event {
+	ch := make(chan Event, midiTickBufferSize)
+
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+
+	if closed(c.done) {
+		close(ch)
+		return ch
+	}
+	c.subscribers = append(c.subscribers, ch)
+
+	return ch
+}
+
+func (c *MIDIClock) Tick() <-chan int64 {
+	return Tick(c)
+}
+
+func (c *MIDIClock) BPM() float64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.bpm
+}
+
+func (c *MIDIClock) Playing() bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.playing
+}
+
+// SetOnErrorFunc sets the function called when reading the MIDI stream fails,
+// which stops the clock.
+func (c *MIDIClock) SetOnErrorFunc(f func(err error)) {
+	c.onErrorMu.Lock()
+	defer c.onErrorMu.Unlock()
+
+	c.onError = f
+}
+
+func (c *MIDIClock) consume() {
+	r := bufio.NewReader(c.r)
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !closed(c.done) {
+				c.onErrorMu.RLock()
+				onError := c.onError
+				c.onErrorMu.RUnlock()
+
+				if onError != nil {
+					onError(fmt.Errorf("could not read from midi device: %w", err))
+				}
+			}
+			return
+		}
+
+		c.handleByte(b, time.Now())
+	}
+}
+
+func (c *MIDIClock) handleByte(b byte, at time.Time) {
+	switch {
+	case b >= midiTimingClock:
+		// Real-time messages may be interleaved anywhere, even inside other
+		// messages, and do not affect the running status.
+		c.handleRealTime(b, at)
+
+	case b == midiSysExEnd:
+		c.status = 0
+
+	case b >= 0x80:
+		c.status = b
+		c.data = c.data[:0]
+
+	case c.status == midiSongPosition:
+		c.data = append(c.data, b)
+		if len(c.data) == 2 {
+			c.handleSongPosition(int64(c.data[0]) | int64(c.data[1])<<7)
+			c.status = 0
+		}
+	}
+}
+
+func (c *MIDIClock) handleRealTime(b byte, at time.Time) {
+	c.stateMu.Lock()
+
+	switch b {
+	case midiStart:
+		c.pulse = 0
+		c.playing = true
+		c.lastPulseAt = time.Time{}
+
+	case midiContinue:
+		c.playing = true
+		c.lastPulseAt = time.Time{}
+
+	case midiStop:
+		c.playing = false
+
+	case midiTimingClock:
+		c.trackTempo(at)
+
+		if !c.playing {
+			break
+		}
+
+		pulse := c.pulse
+		c.pulse++
+		bpm := c.bpm
+		c.stateMu.Unlock()
+
+		if pulse%midiPulsesPerStep == 0 {
+			c.publish(newEvent(float64(pulse)/midiPulsesPerQuarter, bpm, defaultQuantum, true))
+		}
+		return
+	}
+
+	c.stateMu.Unlock()
+}
+
+// handleSongPosition moves the playhead to the given position, expressed in
+// MIDI beats (sixteenth notes), which maps directly to essaim steps.
+func (c *MIDIClock) handleSongPosition(position int64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.pulse = position * midiPulsesPerStep
+}
+
+func (c *MIDIClock) trackTempo(at time.Time) {
+	if !c.lastPulseAt.IsZero() {
+		interval := at.Sub(c.lastPulseAt)
+		if c.pulseInterval == 0 {
+			c.pulseInterval = interval
+		} else {
+			c.pulseInterval += time.Duration(midiTempoSmoothing * float64(interval-c.pulseInterval))
+		}
+
+		if c.pulseInterval > 0 {
+			c.bpm = float64(time.Minute) / float64(c.pulseInterval*midiPulsesPerQuarter)
+		}
+	}
+
+	c.lastPulseAt = at
+}
+
+func (c *MIDIClock) publish(event Event) {
+	c.subscribersMu.RLock()
+	defer c.subscribersMu.RUnlock()
+
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Drop the step rather than stalling the MIDI stream on a slow
+			// consumer.
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package clock
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func pulses(n int) []byte {
+	return bytes.Repeat([]byte{midiTimingClock}, n)
+}
+
+func TestMIDIClockSteps(t *testing.T) {
+	var stream []byte
+	stream = append(stream, midiStart)
+	stream = append(stream, pulses(2*midiPulsesPerStep)...)
+	stream = append(stream, midiStop)
+	// Pulses received while stopped do not move the playhead.
+	stream = append(stream, pulses(midiPulsesPerStep)...)
+	// Song position 8, in sixteenth notes, sent as two 7-bit bytes.
+	stream = append(stream, midiSongPosition, 8, 0)
+	stream = append(stream, midiContinue)
+	stream = append(stream, pulses(2*midiPulsesPerStep)...)
+
+	c := NewMIDIClock(bytes.NewReader(stream))
+	events := c.Events()
+	c.Start()
+	defer c.Close()
+
+	want := []int64{0, 1, 8, 9}
+	for _, step := range want {
+		select {
+		case event := <-events:
+			if event.Step != step {
+				t.Fatalf("got step %d, want %d", event.Step, step)
+			}
+			if !event.Playing {
+				t.Fatalf("step %d is not playing", event.Step)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for step %d", step)
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("got unexpected step %d", event.Step)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMIDIClockStartResets(t *testing.T) {
+	c := NewMIDIClock(bytes.NewReader(nil))
+	events := c.Events()
+
+	at := time.Now()
+	for _, b := range []byte{midiSongPosition, 16, 0, midiContinue, midiTimingClock, midiStop, midiStart, midiTimingClock} {
+		c.handleByte(b, at)
+	}
+
+	for _, want := range []int64{16, 0} {
+		if event := <-events; event.Step != want {
+			t.Fatalf("got step %d, want %d", event.Step, want)
+		}
+	}
+}
+
+func TestMIDIClockTempo(t *testing.T) {
+	c := NewMIDIClock(bytes.NewReader(nil))
+
+	// At 120 bpm, a quarter note lasts 500ms, and a pulse 500ms / 24.
+	interval := 500 * time.Millisecond / midiPulsesPerQuarter
+	at := time.Now()
+	for range midiPulsesPerQuarter {
+		c.handleByte(midiTimingClock, at)
+		at = at.Add(interval)
+	}
+
+	if bpm := c.BPM(); bpm < 119.9 || bpm > 120.1 {
+		t.Fatalf("got %.2f bpm, want 120", bpm)
+	}
+}
+
+func TestMIDIClockError(t *testing.T) {
+	errUnplugged := errors.New("device unplugged")
+	c := NewMIDIClock(io.MultiReader(bytes.NewReader(pulses(2)), iotest.ErrReader(errUnplugged)))
+	defer c.Close()
+
+	errs := make(chan error, 1)
+	c.SetOnErrorFunc(func(err error) {
+		errs <- err
+	})
+	c.Start()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errUnplugged) {
+			t.Fatalf("got error %v, want %v", err, errUnplugged)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got no error for a failing device")
+	}
+}
@@ -0,0 +1,50 @@
+package clock
+
+import "time"
+
+// OffsetClock shifts the events of another clock in time, to compensate for
+// the latency of an output. A positive latency fires the steps early, so that
+// an output lagging by that much lands on the beat; a negative latency fires
+// them late.
+//
+// Steps are extrapolated from the last event received at the tempo it
+// carries, and never run more than the latency ahead of the source clock.
+type OffsetClock struct {
+	clock    Clock
+	schedule schedule
+}
+
+func NewOffsetClock(clock Clock, latency time.Duration) *OffsetClock {
+	return &OffsetClock{
+		clock: clock,
+		schedule: schedule{
+			latency: latency,
+			Timing:  Timing{Rate: RateSixteenth},
+		},
+	}
+}
+
+// WithLatency returns the given clock compensated for the given output
+// latency, or the clock itself when there is no latency.
+func WithLatency(clock Clock, latency time.Duration) Clock {
+	if latency == 0 {
+		return clock
+	}
+
+	return NewOffsetClock(clock, latency)
+}
+
+func (c *OffsetClock) Latency() time.Duration {
+	return c.schedule.latency
+}
+
+func (c *OffsetClock) Events() <-chan Event {
+	ch := make(chan Event, scheduleBufferSize)
+	go c.schedule.produce(c.clock.Events(), ch)
+
+	return ch
+}
+
+func (c *OffsetClock) Tick() <-chan int64 {
+	return Tick(c)
+}
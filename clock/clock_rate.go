@@ -0,0 +1,128 @@
+package clock
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Rate is a number of steps per beat.
+type Rate float64
+
+const (
+	RateQuarter          Rate = 1
+	RateEighth           Rate = 2
+	RateEighthTriplet    Rate = 3
+	RateSixteenth        Rate = StepsPerBeat
+	RateSixteenthTriplet Rate = 6
+	RateThirtySecond     Rate = 8
+
+	RateHalfTime   = RateEighth
+	RateDoubleTime = RateThirtySecond
+)
+
+var (
+	rateNames = map[string]Rate{
+		"1/4":    RateQuarter,
+		"1/8":    RateEighth,
+		"1/8t":   RateEighthTriplet,
+		"1/16":   RateSixteenth,
+		"1/16t":  RateSixteenthTriplet,
+		"1/32":   RateThirtySecond,
+		"half":   RateHalfTime,
+		"double": RateDoubleTime,
+	}
+)
+
+// ParseRate parses a note division such as "1/8", "1/16t" or "half".
+func ParseRate(s string) (Rate, error) {
+	rate, ok := rateNames[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown rate: %q", s)
+	}
+
+	return rate, nil
+}
+
+// Timing lays steps out at a rate, swinging them by delaying every odd step
+// by a fraction of a step.
+type Timing struct {
+	Rate Rate
+	// Swing is the fraction of a step by which odd steps are delayed.
+	Swing float64
+}
+
+func NewTiming(rate Rate, swing float64) Timing {
+	return Timing{
+		Rate:  rate,
+		Swing: min(max(swing, 0), 1),
+	}
+}
+
+// StepAt returns the step played at the given beat.
+func (t Timing) StepAt(beat float64) int64 {
+	position := beat * float64(t.Rate)
+	step := int64(math.Floor(position))
+
+	if step%2 != 0 && position-float64(step) < t.Swing {
+		step--
+	}
+
+	return step
+}
+
+// StepStart returns the beat at which the given step starts.
+func (t Timing) StepStart(step int64) float64 {
+	position := float64(step)
+	if step%2 != 0 {
+		position += t.Swing
+	}
+
+	return position / float64(t.Rate)
+}
+
+// RateClock plays the steps of another clock at a different rate, such as
+// eighth notes or triplets, and optionally swings them by delaying every odd
+// step by a fraction of a step.
+type RateClock struct {
+	clock    Clock
+	schedule schedule
+}
+
+func NewRateClock(clock Clock, rate Rate, swing float64) *RateClock {
+	return &RateClock{
+		clock: clock,
+		schedule: schedule{
+			Timing: NewTiming(rate, swing),
+		},
+	}
+}
+
+// WithRate returns the given clock played at the given rate and swing, or the
+// clock itself when they match straight sixteenth notes.
+func WithRate(clock Clock, rate Rate, swing float64) Clock {
+	if rate == RateSixteenth && swing == 0 {
+		return clock
+	}
+
+	return NewRateClock(clock, rate, swing)
+}
+
+func (c *RateClock) Rate() Rate {
+	return c.schedule.Rate
+}
+
+func (c *RateClock) Swing() float64 {
+	return c.schedule.Swing
+}
+
+func (c *RateClock) Events() <-chan Event {
+	ch := make(chan Event, scheduleBufferSize)
+	go c.schedule.produce(c.clock.Events(), ch)
+
+	return ch
+}
+
+func (c *RateClock) Tick() <-chan int64 {
+	return Tick(c)
+}
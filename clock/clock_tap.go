@@ -0,0 +1,284 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	tapPollRate = time.Duration(time.Millisecond * 5)
+
+	// tapTimeout is the delay after which a tap starts a new measure instead
+	// of refining the current one.
+	tapTimeout = time.Duration(time.Second * 2)
+	tapHistory = 8
+
+	tapMinTempo = 20.0
+	tapMaxTempo = 999.0
+)
+
+// TapClock is a manual clock whose tempo is set by tapping along the music,
+// for shows without any Link peer or MIDI clock.
+type TapClock struct {
+	// origin is the time at which the beat 0 happened.
+	origin  time.Time
+	bpm     float64
+	taps    []time.Time
+	stateMu sync.RWMutex
+
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewTapClock(bpm float64) *TapClock {
+	return &TapClock{
+		origin: time.Now(),
+		bpm:    bpm,
+		taps:   make([]time.Time, 0, tapHistory),
+		now:    time.Now,
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *TapClock) Start() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.origin = c.now()
+}
+
+func (c *TapClock) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+
+	return nil
+}
+
+func (c *TapClock) Events() <-chan Event {
+	ch := make(chan Event)
+	go c.produce(ch)
+
+	return ch
+}
+
+func (c *TapClock) Tick() <-chan int64 {
+	return Tick(c)
+}
+
+func (c *TapClock) BPM() float64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.bpm
+}
+
+func (c *TapClock) SetTempo(bpm float64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.setTempo(bpm, c.now())
+}
+
+// Tap registers a tap on a beat. Consecutive taps set the tempo to their
+// average interval, and each tap moves the closest beat onto it.
+func (c *TapClock) Tap() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	now := c.now()
+
+	if len(c.taps) > 0 && now.Sub(c.taps[len(c.taps)-1]) > tapTimeout {
+		c.taps = c.taps[:0]
+	}
+	if len(c.taps) == tapHistory {
+		c.taps = append(c.taps[:0], c.taps[1:]...)
+	}
+	c.taps = append(c.taps, now)
+
+	if len(c.taps) > 1 {
+		interval := c.taps[len(c.taps)-1].Sub(c.taps[0]) / time.Duration(len(c.taps)-1)
+		c.setTempo(float64(time.Minute)/float64(interval), now)
+	}
+
+	c.placeBeat(math.Round(c.beatAt(now)), now)
+}
+
+// Nudge moves the beat forward, or backward for a negative duration.
+func (c *TapClock) Nudge(d time.Duration) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.origin = c.origin.Add(-d)
+}
+
+// Resync makes the current moment the closest downbeat.
+func (c *TapClock) Resync() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	now := c.now()
+	c.placeBeat(math.Round(c.beatAt(now)/defaultQuantum)*defaultQuantum, now)
+}
+
+// HandleCommand applies a textual command: "tap", "resync", "nudge <ms>" or
+// "tempo <bpm>".
+func (c *TapClock) HandleCommand(cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return errors.New("empty command")
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "tap":
+		c.Tap()
+
+	case "resync":
+		c.Resync()
+
+	case "nudge":
+		if len(fields) != 2 {
+			return fmt.Errorf("nudge expects a duration in milliseconds")
+		}
+		ms, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("could not parse nudge duration: %w", err)
+		}
+		c.Nudge(time.Duration(ms * float64(time.Millisecond)))
+
+	case "tempo":
+		if len(fields) != 2 {
+			return fmt.Errorf("tempo expects a bpm")
+		}
+		bpm, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("could not parse tempo: %w", err)
+		}
+		c.SetTempo(bpm)
+
+	default:
+		return fmt.Errorf("unknown command: %q", fields[0])
+	}
+
+	return nil
+}
+
+// ListenCommands applies the commands received as MESSAGE_TYPE_TAP frames on
+// the given address, which may be a multicast group, until the context is
+// done. Frames failing authentication are dropped, as with any receiver.
+func (c *TapClock) ListenCommands(ctx context.Context, addr netip.AddrPort, auth protocol.Auth) error {
+	var conn *net.UDPConn
+	var err error
+
+	if addr.Addr().IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, net.UDPAddrFromAddrPort(addr))
+	} else {
+		conn, err = net.ListenUDP("udp", net.UDPAddrFromAddrPort(addr))
+	}
+	if err != nil {
+		return fmt.Errorf("could not listen for tap commands: %w", err)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.done:
+		}
+		conn.Close()
+	}()
+
+	receiver := protocol.NewReceiver(auth)
+
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error while reading tap commands: %w", err)
+		}
+
+		frame, err := receiver.Receive(b[:n])
+		if err != nil {
+			fmt.Printf("discarding tap command: %s\n", err)
+			continue
+		}
+		if frame.Header.Type != essaimbp.MESSAGE_TYPE_TAP {
+			continue
+		}
+
+		if err := c.HandleCommand(string(frame.Payload)); err != nil {
+			fmt.Printf("could not handle tap command: %s\n", err)
+		}
+	}
+}
+
+func (c *TapClock) produce(ch chan Event) {
+	defer close(ch)
+
+	t := time.NewTicker(tapPollRate)
+	defer t.Stop()
+
+	started := false
+	lastStep := int64(0)
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+		}
+
+		c.stateMu.RLock()
+		event := newEvent(c.beatAt(c.now()), c.bpm, defaultQuantum, true)
+		c.stateMu.RUnlock()
+
+		if started && event.Step == lastStep {
+			continue
+		}
+
+		select {
+		case ch <- event:
+		case <-c.done:
+			return
+		}
+
+		started = true
+		lastStep = event.Step
+	}
+}
+
+// beatAt must be called with the state lock held.
+func (c *TapClock) beatAt(at time.Time) float64 {
+	return at.Sub(c.origin).Minutes() * c.bpm
+}
+
+// setTempo changes the tempo while keeping the current beat in place. It must
+// be called with the state lock held.
+func (c *TapClock) setTempo(bpm float64, at time.Time) {
+	beat := c.beatAt(at)
+
+	c.bpm = min(max(bpm, tapMinTempo), tapMaxTempo)
+	c.placeBeat(beat, at)
+}
+
+// placeBeat shifts the timeline so that the given beat is played at the given
+// time. It must be called with the state lock held.
+func (c *TapClock) placeBeat(beat float64, at time.Time) {
+	c.origin = at.Add(-time.Duration(beat / c.bpm * float64(time.Minute)))
+}
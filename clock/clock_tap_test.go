@@ -0,0 +1,195 @@
+package clock
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/protocol"
+)
+
+// newTestTapClock returns a tap clock whose time only moves when told to.
+func newTestTapClock(bpm float64) (*TapClock, *time.Time) {
+	now := time.Unix(1000, 0)
+
+	c := NewTapClock(bpm)
+	c.now = func() time.Time { return now }
+	c.Start()
+
+	return c, &now
+}
+
+func currentBeat(c *TapClock) float64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.beatAt(c.now())
+}
+
+func expectNear(t *testing.T, what string, got float64, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-6 {
+		t.Fatalf("got %s %g, want %g", what, got, want)
+	}
+}
+
+func TestTapClockAverage(t *testing.T) {
+	c, now := newTestTapClock(90)
+
+	// The tempo is the average interval between the taps, whatever their
+	// jitter.
+	for _, interval := range []time.Duration{0, 490, 510, 500} {
+		*now = now.Add(interval * time.Millisecond)
+		c.Tap()
+	}
+	expectNear(t, "tempo", c.BPM(), 120)
+
+	// Every tap moves the closest beat onto it.
+	expectNear(t, "beat", currentBeat(c), math.Round(currentBeat(c)))
+}
+
+func TestTapClockTimeout(t *testing.T) {
+	c, now := newTestTapClock(90)
+
+	c.Tap()
+	*now = now.Add(500 * time.Millisecond)
+	c.Tap()
+	expectNear(t, "tempo", c.BPM(), 120)
+
+	// A tap long after the last one starts a new measure, rather than being
+	// averaged with the previous ones.
+	*now = now.Add(tapTimeout + time.Second)
+	c.Tap()
+	expectNear(t, "tempo", c.BPM(), 120)
+
+	*now = now.Add(250 * time.Millisecond)
+	c.Tap()
+	expectNear(t, "tempo", c.BPM(), 240)
+}
+
+func TestTapClockHistory(t *testing.T) {
+	c, now := newTestTapClock(90)
+
+	// Only the last taps are averaged, the first interval being forgotten.
+	c.Tap()
+	*now = now.Add(time.Second)
+	for range tapHistory {
+		c.Tap()
+		*now = now.Add(500 * time.Millisecond)
+	}
+	expectNear(t, "tempo", c.BPM(), 120)
+}
+
+func TestTapClockTempo(t *testing.T) {
+	c, now := newTestTapClock(120)
+
+	*now = now.Add(1250 * time.Millisecond)
+	expectNear(t, "beat", currentBeat(c), 2.5)
+
+	// The tempo changes from the current beat on.
+	c.SetTempo(60)
+	expectNear(t, "beat", currentBeat(c), 2.5)
+	*now = now.Add(time.Second)
+	expectNear(t, "beat", currentBeat(c), 3.5)
+
+	c.SetTempo(1)
+	expectNear(t, "tempo", c.BPM(), tapMinTempo)
+	c.SetTempo(5000)
+	expectNear(t, "tempo", c.BPM(), tapMaxTempo)
+}
+
+func TestTapClockNudge(t *testing.T) {
+	c, now := newTestTapClock(120)
+
+	*now = now.Add(time.Second)
+	expectNear(t, "beat", currentBeat(c), 2)
+
+	// A beat lasts 500ms at 120 bpm.
+	c.Nudge(100 * time.Millisecond)
+	expectNear(t, "beat", currentBeat(c), 2.2)
+
+	c.Nudge(-250 * time.Millisecond)
+	expectNear(t, "beat", currentBeat(c), 1.7)
+}
+
+func TestTapClockResync(t *testing.T) {
+	c, now := newTestTapClock(120)
+
+	// The closest downbeat is the one of the current bar.
+	*now = now.Add(2650 * time.Millisecond)
+	expectNear(t, "beat", currentBeat(c), 5.3)
+	c.Resync()
+	expectNear(t, "beat", currentBeat(c), 4)
+
+	// Or the one of the next bar.
+	*now = now.Add(1250 * time.Millisecond)
+	expectNear(t, "beat", currentBeat(c), 6.5)
+	c.Resync()
+	expectNear(t, "beat", currentBeat(c), 8)
+}
+
+func TestTapClockCommands(t *testing.T) {
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.LocalAddr().(*net.UDPAddr).AddrPort()
+	listener.Close()
+
+	auth := protocol.Auth{Key: []byte("essaim test key")}
+	c := NewTapClock(120)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ListenCommands(ctx, addr, auth)
+
+	conn, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Commands which are not signed with the key are dropped.
+	unsigned := protocol.NewSender(conn, protocol.Auth{})
+	signed := protocol.NewSender(conn, auth)
+
+	timeout := time.After(time.Second)
+	for c.BPM() != 90 {
+		unsigned.Send(essaimbp.MESSAGE_TYPE_TAP, []byte("tempo 60"))
+		signed.Send(essaimbp.MESSAGE_TYPE_TAP, []byte("tempo 90"))
+
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("got tempo %g, want 90", c.BPM())
+		}
+	}
+
+	signed.Send(essaimbp.MESSAGE_TYPE_TAP, []byte("tempo 100"))
+	for _, cmd := range []string{"tempo 60", "resync", "tap"} {
+		unsigned.Send(essaimbp.MESSAGE_TYPE_TAP, []byte(cmd))
+	}
+	signed.Send(essaimbp.MESSAGE_TYPE_TAP, []byte("tempo 110"))
+
+	for c.BPM() != 110 {
+		if bpm := c.BPM(); bpm != 90 && bpm != 100 {
+			t.Fatalf("got tempo %g from an unsigned command", bpm)
+		}
+
+		select {
+		case <-time.After(time.Millisecond):
+		case <-timeout:
+			t.Fatalf("got tempo %g, want 110", c.BPM())
+		}
+	}
+
+	if err := c.HandleCommand("jump"); err == nil {
+		t.Fatal("got no error for an unknown command")
+	}
+}
@@ -0,0 +1,215 @@
+package clock
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// virtualBufferSize is the number of steps kept for a subscriber of a virtual
+// clock before the next ones are dropped.
+const virtualBufferSize = 64
+
+// VirtualClock is a clock that only moves when it is told to, either step by
+// step or by an amount of simulated time at its current tempo.
+//
+// Events are delivered without blocking: once Advance, AdvanceTime or SeekStep
+// return, the steps they produced are buffered for every subscriber, those
+// which do not fit in the buffer of a subscriber being dropped. The channels
+// of the subscribers are closed along the clock.
+type VirtualClock struct {
+	beat     float64
+	nextStep int64
+	bpm      float64
+	paused   bool
+	stateMu  sync.RWMutex
+
+	subscribers   []chan Event
+	subscribersMu sync.RWMutex
+
+	// emitMu serializes the emission of events so that subscribers always
+	// receive steps in order.
+	emitMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewVirtualClock(bpm float64) *VirtualClock {
+	return &VirtualClock{
+		bpm:  bpm,
+		done: make(chan struct{}),
+	}
+}
+
+func (c *VirtualClock) Start() {
+}
+
+func (c *VirtualClock) Close() error {
+	c.closeOnce.Do(func() {
+		c.subscribersMu.Lock()
+		defer c.subscribersMu.Unlock()
+
+		close(c.done)
+		closeSubscribers(c.subscribers)
+		c.subscribers = nil
+	})
+
+	return nil
+}
+
+func (c *VirtualClock) Events() <-chan Event {
+	ch := make(chan Event, virtualBufferSize)
+
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+
+	if closed(c.done) {
+		close(ch)
+		return ch
+	}
+	c.subscribers = append(c.subscribers, ch)
+
+	return ch
+}
+
+func (c *VirtualClock) Tick() <-chan int64 {
+	return Tick(c)
+}
+
+func (c *VirtualClock) BPM() float64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.bpm
+}
+
+func (c *VirtualClock) SetTempo(bpm float64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.bpm = bpm
+}
+
+// Beat returns the current position of the clock, in beats.
+func (c *VirtualClock) Beat() float64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.beat
+}
+
+func (c *VirtualClock) Paused() bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.paused
+}
+
+func (c *VirtualClock) Pause() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.paused = true
+}
+
+func (c *VirtualClock) Resume() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.paused = false
+}
+
+// Advance moves the clock forward by the given number of steps, emitting each
+// of them. It does nothing while the clock is paused.
+func (c *VirtualClock) Advance(steps int) {
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+
+	for range steps {
+		c.stateMu.Lock()
+		if c.paused {
+			c.stateMu.Unlock()
+			return
+		}
+		step := c.nextStep
+		c.beat = float64(step) / StepsPerBeat
+		c.nextStep++
+		event := c.event(step)
+		c.stateMu.Unlock()
+
+		c.publish(event)
+	}
+}
+
+// AdvanceTime moves the clock forward by the given amount of simulated time
+// at the current tempo, emitting every step started in the meantime. It does
+// nothing while the clock is paused.
+func (c *VirtualClock) AdvanceTime(d time.Duration) {
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+
+	c.stateMu.Lock()
+	if c.paused {
+		c.stateMu.Unlock()
+		return
+	}
+	c.beat += d.Minutes() * c.bpm
+	lastStep := int64(math.Floor(c.beat * StepsPerBeat))
+	c.stateMu.Unlock()
+
+	for {
+		c.stateMu.Lock()
+		step := c.nextStep
+		if step > lastStep {
+			c.stateMu.Unlock()
+			return
+		}
+		c.nextStep++
+		event := c.event(step)
+		c.stateMu.Unlock()
+
+		c.publish(event)
+	}
+}
+
+// SeekStep moves the clock to the beginning of the given step and emits it,
+// even while paused, so that consumers follow the jump.
+func (c *VirtualClock) SeekStep(step int64) {
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+
+	c.stateMu.Lock()
+	c.beat = float64(step) / StepsPerBeat
+	c.nextStep = step + 1
+	event := c.event(step)
+	c.stateMu.Unlock()
+
+	c.publish(event)
+}
+
+// event must be called with the state lock held.
+func (c *VirtualClock) event(step int64) Event {
+	e := newEvent(float64(step)/StepsPerBeat, c.bpm, defaultQuantum, !c.paused)
+
+	position := c.beat * StepsPerBeat
+	if int64(math.Floor(position)) == step {
+		e.Phase = position - math.Floor(position)
+	}
+
+	return e
+}
+
+func (c *VirtualClock) publish(event Event) {
+	c.subscribersMu.RLock()
+	defer c.subscribersMu.RUnlock()
+
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Drop the step rather than stalling the clock on a slow
+			// consumer.
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package clock
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// advance advances the clock without waiting for the events to be read.
+func advance(c *VirtualClock, steps int) {
+	go c.Advance(steps)
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a step")
+		return Event{}
+	}
+}
+
+func expectSteps(t *testing.T, events <-chan Event, steps ...int64) {
+	t.Helper()
+
+	for _, step := range steps {
+		if event := receive(t, events); event.Step != step {
+			t.Fatalf("got step %d, want %d", event.Step, step)
+		}
+	}
+}
+
+func expectNothing(t *testing.T, events <-chan Event) {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		t.Fatalf("got unexpected step %d", event.Step)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestVirtualClockSteps(t *testing.T) {
+	c := NewVirtualClock(120)
+	defer c.Close()
+	events := c.Events()
+
+	advance(c, 3)
+	expectSteps(t, events, 0, 1, 2)
+
+	go c.SeekStep(10)
+	event := receive(t, events)
+	if event.Step != 10 || event.Beat != 2.5 || event.Bar != 0 {
+		t.Fatalf("got step %d at beat %g of bar %d, want step 10 at beat 2.5 of bar 0", event.Step, event.Beat, event.Bar)
+	}
+
+	advance(c, 2)
+	expectSteps(t, events, 11, 12)
+
+	c.Pause()
+	c.Advance(1)
+	expectNothing(t, events)
+	c.Resume()
+
+	// A beat lasts 500ms at 120 bpm, so a second moves the clock by 8 steps,
+	// from the beginning of step 12 to that of step 20.
+	go c.AdvanceTime(time.Second)
+	expectSteps(t, events, 13, 14, 15, 16, 17, 18, 19, 20)
+	expectNothing(t, events)
+
+	if beat := c.Beat(); beat != 5 {
+		t.Fatalf("got beat %g, want 5", beat)
+	}
+}
+
+func TestVirtualClockSubscribers(t *testing.T) {
+	c := NewVirtualClock(120)
+	defer c.Close()
+	first, second := c.Events(), c.Events()
+
+	advance(c, 2)
+	for _, step := range []int64{0, 1} {
+		expectSteps(t, first, step)
+		expectSteps(t, second, step)
+	}
+}
+
+// fastBPM makes a sixteenth note last 25ms, so that the clocks extrapolating
+// steps in real time reach them quickly.
+const fastBPM = 600
+
+func TestRateClockSwing(t *testing.T) {
+	c := NewVirtualClock(fastBPM)
+	defer c.Close()
+	events := WithRate(c, RateSixteenth, 0.5).Events()
+
+	// Odd steps are delayed by half a step.
+	wantBeats := []float64{0, 0.375, 0.5, 0.875}
+	for step, want := range wantBeats {
+		advance(c, 1)
+
+		event := receive(t, events)
+		if event.Step != int64(step) || math.Abs(event.Beat-want) > 1e-9 {
+			t.Fatalf("got step %d at beat %g, want step %d at beat %g", event.Step, event.Beat, step, want)
+		}
+	}
+}
+
+func TestRateClockEighths(t *testing.T) {
+	c := NewVirtualClock(fastBPM)
+	defer c.Close()
+	events := WithRate(c, RateEighth, 0).Events()
+
+	advance(c, 1)
+	expectSteps(t, events, 0)
+
+	// The next eighth note is two sixteenth notes of the source away.
+	advance(c, 2)
+	event := receive(t, events)
+	if event.Step != 1 || event.Beat != 0.5 || event.Rate != RateEighth {
+		t.Fatalf("got step %d at beat %g at rate %g, want step 1 at beat 0.5 at rate %g", event.Step, event.Beat, event.Rate, RateEighth)
+	}
+}
+
+func TestOffsetClockLatency(t *testing.T) {
+	c := NewVirtualClock(fastBPM)
+	defer c.Close()
+
+	// A latency of two steps fires the steps two steps early.
+	step := time.Minute / fastBPM / StepsPerBeat
+	events := WithLatency(c, 2*step).Events()
+
+	advance(c, 1)
+	expectSteps(t, events, 2)
+
+	advance(c, 1)
+	expectSteps(t, events, 3)
+
+	// Jumping far ahead restarts the output from the new position.
+	go c.SeekStep(40)
+	expectSteps(t, events, 42)
+}
+
+func TestRateClockAbandoned(t *testing.T) {
+	c := NewVirtualClock(fastBPM)
+	defer c.Close()
+
+	// The steps of a subscriber which stopped reading are dropped, without
+	// stalling the source for the others.
+	WithRate(c, RateEighth, 0).Events()
+	events := c.Events()
+
+	steps := 4 * scheduleBufferSize
+	advance(c, steps)
+	for step := range steps {
+		expectSteps(t, events, int64(step))
+	}
+}
+
+func TestVirtualClockAbandoned(t *testing.T) {
+	c := NewVirtualClock(120)
+	defer c.Close()
+	abandoned := c.Events()
+	events := c.Events()
+
+	// Advancing returns even though a subscriber stopped reading, whose
+	// steps past its buffer are dropped.
+	steps := 2 * virtualBufferSize
+	for step := range steps {
+		c.Advance(1)
+		expectSteps(t, events, int64(step))
+	}
+
+	if len(abandoned) != virtualBufferSize {
+		t.Fatalf("got %d steps for the abandoned subscriber, want %d", len(abandoned), virtualBufferSize)
+	}
+	expectSteps(t, abandoned, 0)
+}
+
+func expectClosed[T any](t *testing.T, ch <-chan T) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the channel to be closed")
+		}
+	}
+}
+
+func TestVirtualClockClose(t *testing.T) {
+	c := NewVirtualClock(fastBPM)
+	events := c.Events()
+	ticks := Tick(c)
+	rated := WithRate(c, RateEighth, 0.5).Events()
+	delayed := WithLatency(c, -time.Millisecond).Events()
+
+	advance(c, 2)
+	expectSteps(t, events, 0, 1)
+	if step := <-ticks; step != 0 {
+		t.Fatalf("got tick %d, want 0", step)
+	}
+
+	c.Close()
+	expectClosed(t, events)
+	expectClosed(t, ticks)
+	expectClosed(t, rated)
+	expectClosed(t, delayed)
+
+	// Subscribing once closed returns a closed channel.
+	expectClosed(t, c.Events())
+	expectClosed(t, WithRate(c, RateEighth, 0).Events())
+
+	// Advancing a closed clock is a no-op.
+	c.Advance(1)
+}
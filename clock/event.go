@@ -0,0 +1,67 @@
+package clock
+
+import "math"
+
+const (
+	StepsPerBeat = 4
+
+	defaultQuantum = 4
+)
+
+// Event is published by a clock each time a new step (a sixteenth note)
+// begins.
+type Event struct {
+	// Step is the number of steps elapsed since the start of the timeline.
+	Step int64
+	// Beat is the position on the timeline, in beats.
+	Beat float64
+	// Bar is the number of bars elapsed since the start of the timeline.
+	Bar int64
+	// Phase is the position within the step, between 0 and 1.
+	Phase float64
+
+	BPM     float64
+	Quantum float64
+	Playing bool
+	// Rate is the number of steps per beat.
+	Rate Rate
+}
+
+func newEvent(beat float64, bpm float64, quantum float64, playing bool) Event {
+	position := beat * StepsPerBeat
+	step := math.Floor(position)
+
+	return Event{
+		Step:    int64(step),
+		Beat:    beat,
+		Bar:     int64(math.Floor(beat / quantum)),
+		Phase:   position - step,
+		BPM:     bpm,
+		Quantum: quantum,
+		Playing: playing,
+		Rate:    RateSixteenth,
+	}
+}
+
+// StepInBar returns the index of the step within the current bar.
+func (e Event) StepInBar() int64 {
+	stepsPerBar := int64(e.Quantum * float64(e.rate()))
+	if stepsPerBar <= 0 {
+		return e.Step
+	}
+
+	step := e.Step % stepsPerBar
+	if step < 0 {
+		step += stepsPerBar
+	}
+
+	return step
+}
+
+func (e Event) rate() Rate {
+	if e.Rate <= 0 {
+		return RateSixteenth
+	}
+
+	return e.Rate
+}
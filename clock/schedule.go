@@ -0,0 +1,98 @@
+package clock
+
+import (
+	"math"
+	"time"
+)
+
+// scheduleBufferSize is the number of steps kept for a subscriber of a
+// re-timed clock before the next ones are dropped.
+const scheduleBufferSize = 16
+
+// schedule re-times the events of a clock: steps are extrapolated from the
+// last event received at the tempo it carries, shifted by a latency, laid out
+// at a given rate and swung. The steps are dropped rather than stalling the
+// source when out is full, and produce returns once the source is closed.
+type schedule struct {
+	latency time.Duration
+	Timing
+}
+
+func (s schedule) produce(in <-chan Event, out chan Event) {
+	defer close(out)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	var (
+		anchor   Event
+		anchorAt time.Time
+		started  bool
+		last     int64
+	)
+
+	for {
+		select {
+		case event, ok := <-in:
+			if !ok {
+				return
+			}
+			anchor, anchorAt = event, time.Now()
+
+		case <-timer.C:
+		}
+
+		position := s.beatAt(anchor, anchorAt, time.Now())
+		limit := s.limit(anchor)
+		target := s.StepAt(min(position, limit))
+
+		if !started || target < last-int64(s.Rate) || target-last > int64(anchor.Quantum*float64(s.Rate)) {
+			last = target - 1
+			started = true
+		}
+
+		for ; last < target; last++ {
+			select {
+			case out <- s.event(last+1, anchor):
+			default:
+			}
+		}
+
+		// Wait for the source when it stopped moving or when the output is
+		// already as far ahead of it as allowed.
+		next := s.StepStart(last + 1)
+		if anchor.BPM <= 0 || next > limit {
+			continue
+		}
+
+		wait := time.Duration((next - position) / anchor.BPM * float64(time.Minute))
+		timer.Reset(max(wait, 0))
+	}
+}
+
+// beatAt returns the beat that the output must be showing at the given time.
+func (s schedule) beatAt(anchor Event, anchorAt time.Time, at time.Time) float64 {
+	elapsed := at.Add(s.latency).Sub(anchorAt)
+	return anchor.Beat + elapsed.Minutes()*anchor.BPM
+}
+
+// limit returns the furthest beat the output may reach before the source
+// catches up: one source step past the latency.
+func (s schedule) limit(anchor Event) float64 {
+	return anchor.Beat + max(s.latency, 0).Minutes()*anchor.BPM + 1.0/float64(anchor.rate())
+}
+
+func (s schedule) event(step int64, anchor Event) Event {
+	beat := s.StepStart(step)
+
+	return Event{
+		Step:    step,
+		Beat:    beat,
+		Bar:     int64(math.Floor(beat / anchor.Quantum)),
+		BPM:     anchor.BPM,
+		Quantum: anchor.Quantum,
+		Playing: anchor.Playing,
+		Rate:    s.Rate,
+	}
+}
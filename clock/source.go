@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	SourceLink = "link"
+	SourceMIDI = "midi"
+	SourceFake = "fake"
+	SourceTap  = "tap"
+)
+
+// Source is a Clock owned by a command, which starts it once every consumer
+// is ready and closes it on exit.
+type Source interface {
+	Clock
+	Start()
+	Close() error
+}
+
+type SourceConfig struct {
+	Kind string
+	BPM  float64
+
+	// MIDIDevice is the raw MIDI device the MIDI clock is read from.
+	MIDIDevice string
+
+	// TapAddr is the address on which the tap clock receives commands, if
+	// valid, authenticated with Auth.
+	TapAddr netip.AddrPort
+	Auth    protocol.Auth
+
+	// OnError is called with the errors stopping the clock, such as those
+	// of the MIDI device or of the tap commands listener.
+	OnError func(err error)
+}
+
+func NewSource(cfg SourceConfig) (Source, error) {
+	switch cfg.Kind {
+	case SourceLink:
+		return NewLinkClock(cfg.BPM), nil
+	case SourceMIDI:
+		c, err := OpenMIDIClock(cfg.MIDIDevice)
+		if err != nil {
+			return nil, err
+		}
+		c.SetOnErrorFunc(cfg.OnError)
+		return c, nil
+	case SourceFake:
+		return NewFakeClock(cfg.BPM), nil
+	case SourceTap:
+		c := NewTapClock(cfg.BPM)
+		if cfg.TapAddr.IsValid() {
+			go func() {
+				err := c.ListenCommands(context.Background(), cfg.TapAddr, cfg.Auth)
+				if err != nil && cfg.OnError != nil {
+					cfg.OnError(fmt.Errorf("stopped listening for tap commands: %w", err))
+				}
+			}()
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown clock source: %q", cfg.Kind)
+	}
+}
@@ -1,5 +1,60 @@
 package clock
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrClosed = errors.New("clock closed")
+)
+
 type Clock interface {
-	Tick() <-chan int64
+	// Events returns a channel of the steps of the clock, closed once the
+	// clock is.
+	Events() <-chan Event
+}
+
+// Tick adapts the events of a clock to a plain channel of step numbers, as
+// consumed before clocks published beat events.
+func Tick(c Clock) <-chan int64 {
+	events := c.Events()
+	ch := make(chan int64)
+
+	go func() {
+		for event := range events {
+			ch <- event.Step
+		}
+		close(ch)
+	}()
+
+	return ch
+}
+
+func closed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func closeSubscribers(subscribers []chan Event) {
+	for _, ch := range subscribers {
+		close(ch)
+	}
+}
+
+// TempoSetter is implemented by clocks whose tempo can be set by essaim.
+type TempoSetter interface {
+	BPM() float64
+	SetTempo(bpm float64)
+}
+
+// Tapper is implemented by clocks following taps from the operator.
+type Tapper interface {
+	Tap()
+	Nudge(d time.Duration)
+	Resync()
 }
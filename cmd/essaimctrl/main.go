@@ -4,19 +4,43 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log"
+	"net"
 	"net/netip"
+	"os"
 
 	"essaim.dev/essaim/clock"
 	"essaim.dev/essaim/mikrocontroller"
+	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 	// _ "net/http/pprof"
 )
 
 var (
-	addrFlag string
+	addrFlag              string
+	interfaceFlag         string
+	multicastTTLFlag      int
+	multicastLoopbackFlag bool
+	clockFlag             string
+	midiDeviceFlag        string
+	tapAddrFlag           string
+	stepsFlag             int
+	authKeyFlag           string
+	transportFlag         string
 )
 
 func init() {
 	flag.StringVar(&addrFlag, "addr", "224.2.2.3:9999", "ip address and port used to send instructions")
+	flag.StringVar(&interfaceFlag, "interface", "", "network interface used for multicast, chosen by the system if empty")
+	flag.IntVar(&multicastTTLFlag, "multicast-ttl", 1, "number of routers the multicast messages may go through, plus one")
+	flag.BoolVar(&multicastLoopbackFlag, "multicast-loopback", true, "whether the multicast messages sent are also received on this host")
+	flag.StringVar(&clockFlag, "clock", clock.SourceLink, "clock source used to follow the music: link, midi, fake or tap")
+	flag.StringVar(&midiDeviceFlag, "midi-device", "/dev/snd/midiC1D0", "raw midi device to read the midi clock from")
+	flag.StringVar(&tapAddrFlag, "tap-addr", "", "ip address and port on which the tap clock receives commands, sent with essaimtap")
+	flag.IntVar(&stepsFlag, "steps", 16, "number of steps in each pattern, from 1 to 64")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to send them unauthenticated")
+	flag.StringVar(&transportFlag, "transport", transport.UDP, "transport of the messages: udp, to the multicast group at addr, or tcp, listening for the nodes on addr")
 }
 
 func main() {
@@ -48,16 +72,73 @@ func run() error {
 		return fmt.Errorf("could not not parse ip address: %w", err)
 	}
 
-	linkClock := clock.NewLinkClock(120.0)
-	defer linkClock.Close()
+	var iface *net.Interface
+	if interfaceFlag != "" {
+		iface, err = net.InterfaceByName(interfaceFlag)
+		if err != nil {
+			log.Fatalf("could not not find interface with given name: %s", err)
+		}
+	}
+
+	if stepsFlag < pattern.MinSteps || stepsFlag > pattern.MaxSteps {
+		return fmt.Errorf("invalid step count: %d", stepsFlag)
+	}
+
+	auth := protocol.Auth{Key: []byte(authKeyFlag)}
+
+	var tapAddr netip.AddrPort
+	if tapAddrFlag != "" {
+		tapAddr, err = netip.ParseAddrPort(tapAddrFlag)
+		if err != nil {
+			return fmt.Errorf("could not parse tap address: %w", err)
+		}
+	}
+
+	clk, err := clock.NewSource(clock.SourceConfig{
+		Kind:       clockFlag,
+		BPM:        120.0,
+		MIDIDevice: midiDeviceFlag,
+		TapAddr:    tapAddr,
+		Auth:       auth,
+		OnError: func(err error) {
+			fmt.Printf("clock stopped: %s\n", err)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create clock: %w", err)
+	}
+	defer clk.Close()
+
+	if link, ok := clk.(*clock.LinkClock); ok {
+		link.SetOnTempoFunc(func(bpm float64) {
+			fmt.Printf("link tempo changed: %.2f bpm\n", bpm)
+		})
+		link.SetOnPeersFunc(func(peers uint64) {
+			fmt.Printf("link peers changed: %d\n", peers)
+		})
+		link.SetOnPlayingFunc(func(playing bool) {
+			fmt.Printf("link transport changed: playing=%t\n", playing)
+		})
+	}
+
+	udpConfig := transport.UDPConfig{
+		Interface:       iface,
+		TTL:             multicastTTLFlag,
+		DisableLoopback: !multicastLoopbackFlag,
+	}
+
+	conn, err := transport.Listen(transportFlag, addr, udpConfig)
+	if err != nil {
+		return fmt.Errorf("could not open transport: %w", err)
+	}
 
-	c, err := mikrocontroller.NewController(linkClock, 16, addr)
+	c, err := mikrocontroller.NewController(clk, stepsFlag, conn, auth)
 	if err != nil {
 		return fmt.Errorf("could not create mikro controller: %w", err)
 	}
 	defer c.Close()
 
-	linkClock.Start()
+	clk.Start()
 	if err := c.Run(context.Background()); err != nil {
 		return fmt.Errorf("could not run mikro controller: %w", err)
 	}
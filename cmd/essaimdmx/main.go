@@ -1,27 +1,86 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"net"
 	"net/netip"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/dmx"
 	"essaim.dev/essaim/dmxclient"
+	"essaim.dev/essaim/fixture"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 )
 
 var (
-	interfaceFlag string
-	addrFlag      string
-	channelFlag   uint64
+	interfaceFlag         string
+	addrFlag              string
+	multicastTTLFlag      int
+	multicastLoopbackFlag bool
+	channelFlag           uint64
+	routesFlag            string
+	patchFlag             string
+	outputFlag            string
+	universesFlag         int
+	outputAddrFlag        string
+	outputRateFlag        time.Duration
+	breakTimeFlag         time.Duration
+	mabTimeFlag           time.Duration
+	artNetNetFlag         int
+	artNetSubNetFlag      int
+	artNetUniverseFlag    int
+	sacnUniverseFlag      int
+	sacnCIDFlag           string
+	sacnSourceNameFlag    string
+	sacnPriorityFlag      int
+	clockFlag             string
+	midiDeviceFlag        string
+	tapAddrFlag           string
+	latencyFlag           int
+	authKeyFlag           string
+	authWindowFlag        time.Duration
+	transportFlag         string
 )
 
 func init() {
 	flag.StringVar(&interfaceFlag, "interface", "eth0", "")
 	flag.StringVar(&addrFlag, "addr", "224.2.2.3:9999", "ip address and port used to send instructions")
+	flag.IntVar(&multicastTTLFlag, "multicast-ttl", 1, "number of routers the multicast messages may go through, plus one")
+	flag.BoolVar(&multicastLoopbackFlag, "multicast-loopback", true, "whether the multicast messages sent are also received on this host")
 	flag.Uint64Var(&channelFlag, "channel", 0, "")
+	flag.StringVar(&routesFlag, "routes", "", "comma-separated essaim channels and dmx addresses of their rgb fixtures, as channel:address, optionally followed by the rate (1/4, 1/8, 1/8t, 1/16, 1/16t, 1/32, half or double) and swing of their steps, as channel:address:rate:swing, instead of channel at address 1")
+	flag.StringVar(&patchFlag, "patch", "", "json file of the fixture profiles and of the patch, instead of routes")
+	flag.StringVar(&outputFlag, "output", dmx.OutputFTDI, "dmx output: ftdi, for an open dmx usb adapter, enttec, for an enttec dmx usb pro widget, artnet, sacn, or virtual, printing the channels which change")
+	flag.IntVar(&universesFlag, "universes", 1, "number of universes of the artnet, sacn and virtual outputs")
+	flag.StringVar(&outputAddrFlag, "output-addr", "", "ip address and port of the node, or broadcast address, to which artnet and sacn universes are sent, none to send them to the art-net nodes discovered or to the sacn multicast groups")
+	flag.DurationVar(&outputRateFlag, "output-rate", dmx.DefaultRate, "delay between two refreshes of the ftdi, artnet and sacn universes")
+	flag.DurationVar(&breakTimeFlag, "break-time", dmx.DefaultBreakTime, "duration of the break before the packets of the ftdi and enttec outputs, at least 92µs")
+	flag.DurationVar(&mabTimeFlag, "mab-time", dmx.DefaultMABTime, "duration of the mark after break before the packets of the ftdi and enttec outputs, at least 12µs")
+	flag.IntVar(&artNetNetFlag, "artnet-net", 0, "art-net net of the first universe, from 0 to 127")
+	flag.IntVar(&artNetSubNetFlag, "artnet-subnet", 0, "art-net subnet of the first universe, from 0 to 15")
+	flag.IntVar(&artNetUniverseFlag, "artnet-universe", 0, "art-net universe of the first universe, from 0 to 15, the next ones following it")
+	flag.IntVar(&sacnUniverseFlag, "sacn-universe", 1, "sacn universe of the first universe, from 1 to 63999, the next ones following it")
+	flag.StringVar(&sacnCIDFlag, "sacn-cid", "", "uuid identifying this sacn source, which should not change across restarts, a random one if none")
+	flag.StringVar(&sacnSourceNameFlag, "sacn-source-name", "essaim", "name of this sacn source shown by the receivers")
+	flag.IntVar(&sacnPriorityFlag, "sacn-priority", dmx.DefaultSACNPriority, "priority of the sacn universes, from 0 to 200, a console sending them at a higher priority taking over")
+	flag.StringVar(&clockFlag, "clock", clock.SourceLink, "clock source used to follow the music: link, midi, fake or tap")
+	flag.StringVar(&midiDeviceFlag, "midi-device", "/dev/snd/midiC1D0", "raw midi device to read the midi clock from")
+	flag.StringVar(&tapAddrFlag, "tap-addr", "", "ip address and port on which the tap clock receives commands, sent with essaimtap")
+	flag.IntVar(&latencyFlag, "latency", 0, "latency of the output to compensate, in milliseconds (negative to delay the output)")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to accept unauthenticated ones")
+	flag.DurationVar(&authWindowFlag, "auth-window", 0, "largest clock difference accepted with a new sender of authenticated messages, none to not compare clocks, which needs them synchronized")
+	flag.StringVar(&transportFlag, "transport", transport.UDP, "transport of the messages: udp, to the multicast group at addr, or tcp, connecting to the controller at addr")
 }
 
 func main() {
@@ -43,29 +102,192 @@ func run() error {
 		log.Fatalf("could not not find interface with given name: %s", err)
 	}
 
-	linkClock := clock.NewLinkClock(120.0)
-	defer linkClock.Close()
+	var patch *fixture.Patch
+	switch {
+	case patchFlag != "":
+		patch, err = fixture.LoadPatch(patchFlag)
+		if err != nil {
+			return fmt.Errorf("could not load patch: %w", err)
+		}
+
+		// The channels of the imported profiles which could not be resolved
+		// hold a fixed value.
+		for _, name := range slices.Sorted(maps.Keys(patch.Profiles)) {
+			for _, warning := range patch.Profiles[name].Warnings {
+				fmt.Printf("profile %s: %s\n", name, warning)
+			}
+		}
+
+	case routesFlag != "":
+		routes, err := dmxclient.ParseRoutes(routesFlag)
+		if err != nil {
+			return fmt.Errorf("could not parse routes: %w", err)
+		}
+		patch = dmxclient.RoutesPatch(routes)
+
+	default:
+		patch = dmxclient.RoutesPatch([]dmxclient.Route{{Channel: channelFlag, Address: 1}})
+	}
+
+	auth := protocol.Auth{
+		Key:    []byte(authKeyFlag),
+		Window: authWindowFlag,
+	}
+
+	var tapAddr netip.AddrPort
+	if tapAddrFlag != "" {
+		tapAddr, err = netip.ParseAddrPort(tapAddrFlag)
+		if err != nil {
+			return fmt.Errorf("could not parse tap address: %w", err)
+		}
+	}
+
+	src, err := clock.NewSource(clock.SourceConfig{
+		Kind:       clockFlag,
+		BPM:        120.0,
+		MIDIDevice: midiDeviceFlag,
+		TapAddr:    tapAddr,
+		Auth:       auth,
+		OnError: func(err error) {
+			fmt.Printf("clock stopped: %s\n", err)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create clock: %w", err)
+	}
+	defer src.Close()
+
+	latency := time.Duration(latencyFlag) * time.Millisecond
+	clk := clock.WithLatency(src, latency)
+
+	udpConfig := transport.UDPConfig{
+		Interface:       iface,
+		TTL:             multicastTTLFlag,
+		DisableLoopback: !multicastLoopbackFlag,
+	}
+
+	conn, err := transport.Dial(transportFlag, addr, udpConfig)
+	if err != nil {
+		return fmt.Errorf("could not open transport: %w", err)
+	}
+
+	var outputAddr netip.AddrPort
+	if outputAddrFlag != "" {
+		outputAddr, err = netip.ParseAddrPort(outputAddrFlag)
+		if err != nil {
+			return fmt.Errorf("could not parse output address: %w", err)
+		}
+	}
+
+	var cid dmx.CID
+	if sacnCIDFlag != "" {
+		cid, err = dmx.ParseCID(sacnCIDFlag)
+		if err != nil {
+			return fmt.Errorf("could not parse sacn cid: %w", err)
+		}
+	}
 
-	c, err := dmxclient.New(linkClock, 16, iface, addr, channelFlag)
+	output, err := dmx.OpenOutput(dmx.OutputConfig{
+		Kind:         outputFlag,
+		Universes:    universesFlag,
+		Addr:         outputAddr,
+		UDPConfig:    udpConfig,
+		Net:          artNetNetFlag,
+		SubNet:       artNetSubNetFlag,
+		Universe:     artNetUniverseFlag,
+		SACNUniverse: sacnUniverseFlag,
+		CID:          cid,
+		SourceName:   sacnSourceNameFlag,
+		Priority:     sacnPriorityFlag,
+		Rate:         outputRateFlag,
+		BreakTime:    breakTimeFlag,
+		MABTime:      mabTimeFlag,
+	})
 	if err != nil {
+		return fmt.Errorf("could not open dmx output: %w", err)
+	}
+
+	switch output := output.(type) {
+	case *dmx.Virtual:
+		output.SetOnRenderFunc(printChanges())
+
+	case *dmx.EnttecPro:
+		if err := printWidget(output); err != nil {
+			output.Close()
+			return err
+		}
+	}
+
+	c, err := dmxclient.New(clk, 16, conn, output, patch, auth)
+	if err != nil {
+		output.Close()
 		return fmt.Errorf("could not start dmx client: %w", err)
 	}
+	defer c.Close()
 
-	linkClock.Start()
+	src.Start()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Returning on a signal closes the output, letting sACN receivers know at
+	// once that the universes stopped.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	clientStopped := make(chan error, 1)
 	go func() {
-		if err := c.Run(ctx); err != nil {
-			clientStopped <- fmt.Errorf("error while running client: %s", err)
-		}
+		clientStopped <- c.Run(ctx)
 	}()
 
-	if err := <-clientStopped; err != nil {
-		return fmt.Errorf("client stopped: %w", err)
+	select {
+	case err := <-clientStopped:
+		if err != nil {
+			return fmt.Errorf("client stopped: %w", err)
+		}
+		return nil
+
+	case <-ctx.Done():
+		// The client stops rendering before the output is closed.
+		<-clientStopped
+		return nil
+	}
+}
+
+// printWidget prints the serial number and the timings of an Enttec widget.
+func printWidget(e *dmx.EnttecPro) error {
+	serial, err := e.SerialNumber()
+	if err != nil {
+		return err
 	}
 
+	params, err := e.Params()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("enttec widget %d, firmware %d.%d, break %s, mark after break %s, %d packets/s\n",
+		serial, params.Firmware>>8, params.Firmware&0xff, params.BreakTime, params.MABTime, params.Rate)
+
 	return nil
 }
+
+// printChanges returns a function printing the channels of the universes
+// which changed since the last render.
+func printChanges() func(universes [][]byte) {
+	var last [][]byte
+
+	return func(universes [][]byte) {
+		for idx, channels := range universes {
+			if idx < len(last) && bytes.Equal(last[idx], channels) {
+				continue
+			}
+
+			// Trailing channels left at zero are not printed.
+			used := len(channels)
+			for used > 0 && channels[used-1] == 0 {
+				used--
+			}
+			fmt.Printf("universe %d: % x\n", idx, channels[:used])
+		}
+
+		last = universes
+	}
+}
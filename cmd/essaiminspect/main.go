@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"essaim.dev/essaim/inspect"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+var (
+	addrFlag       string
+	ifaceFlag      string
+	formatFlag     string
+	colorsFlag     bool
+	channelFlag    int
+	authKeyFlag    string
+	authWindowFlag time.Duration
+)
+
+func init() {
+	flag.StringVar(&addrFlag, "addr", "224.2.2.3:9999", "comma-separated ip addresses and ports of the multicast groups to inspect")
+	flag.StringVar(&ifaceFlag, "interface", "", "network interface used for multicast, chosen by the system if empty")
+	flag.StringVar(&formatFlag, "format", formatText, "output format: text or json, one message per line")
+	flag.BoolVar(&colorsFlag, "colors", true, "show the steps of the patterns as colour swatches in text output")
+	flag.IntVar(&channelFlag, "channel", -1, "only show the messages of the given channel, or every message if negative")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to skip authentication checks")
+	flag.DurationVar(&authWindowFlag, "auth-window", 0, "largest clock difference accepted with a new sender of authenticated messages, none to not compare clocks, which needs them synchronized")
+}
+
+type packet struct {
+	b    []byte
+	from netip.AddrPort
+	at   time.Time
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("error: %s\n", err)
+	}
+}
+
+func run() error {
+	flag.Parse()
+
+	if formatFlag != formatText && formatFlag != formatJSON {
+		return fmt.Errorf("unknown format: %q", formatFlag)
+	}
+
+	var iface *net.Interface
+	if ifaceFlag != "" {
+		var err error
+		iface, err = net.InterfaceByName(ifaceFlag)
+		if err != nil {
+			return fmt.Errorf("could not not parse interface: %w", err)
+		}
+	}
+
+	packets := make(chan packet)
+	errs := make(chan error, 1)
+
+	for _, s := range strings.Split(addrFlag, ",") {
+		addr, err := netip.ParseAddrPort(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("could not not parse ip address: %w", err)
+		}
+
+		conn, err := transport.OpenUDP(addr, transport.UDPConfig{Interface: iface})
+		if err != nil {
+			return fmt.Errorf("could not join %s: %w", addr, err)
+		}
+		defer conn.Close()
+
+		go listen(conn, packets, errs)
+	}
+
+	inspector := inspect.NewInspector(protocol.Auth{
+		Key:    []byte(authKeyFlag),
+		Window: authWindowFlag,
+	})
+
+	for {
+		select {
+		case err := <-errs:
+			return err
+
+		case p := <-packets:
+			m := inspector.Inspect(p.b, p.from, p.at)
+			if channelFlag >= 0 && !m.Concerns(uint64(channelFlag)) {
+				continue
+			}
+
+			if formatFlag == formatJSON {
+				b, err := m.JSON()
+				if err != nil {
+					return fmt.Errorf("could not encode message: %w", err)
+				}
+				fmt.Println(string(b))
+				continue
+			}
+
+			fmt.Println(m.Text(colorsFlag))
+		}
+	}
+}
+
+func listen(conn *transport.UDPConn, packets chan<- packet, errs chan<- error) {
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		n, from, err := conn.ReadFrom(b)
+		if err != nil {
+			errs <- fmt.Errorf("error while reading frames: %w", err)
+			return
+		}
+
+		packets <- packet{
+			b:    append([]byte(nil), b[:n]...),
+			from: from,
+			at:   time.Now(),
+		}
+	}
+}
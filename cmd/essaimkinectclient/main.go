@@ -7,10 +7,14 @@ import (
 	"log"
 	"net"
 	"net/netip"
+	"os"
+	"time"
 
 	"essaim.dev/essaim/client"
 	"essaim.dev/essaim/clock"
 	"essaim.dev/essaim/depthstream"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 	"golang.org/x/exp/shiny/driver"
 )
 
@@ -19,6 +23,14 @@ var (
 	ifaceFlag      string
 	streamAddrFlag string
 	channelFlag    uint64
+	clockFlag      string
+	midiDeviceFlag string
+	tapAddrFlag    string
+	latencyFlag    int
+	rateFlag       string
+	swingFlag      float64
+	authKeyFlag    string
+	authWindowFlag time.Duration
 )
 
 func init() {
@@ -26,7 +38,14 @@ func init() {
 	flag.StringVar(&ifaceFlag, "interface", "", "")
 	flag.StringVar(&streamAddrFlag, "stream-addr", "224.76.78.75:20810", "ip address and port used to send instructions")
 	flag.Uint64Var(&channelFlag, "channel", 0, "")
-
+	flag.StringVar(&clockFlag, "clock", clock.SourceLink, "clock source used to follow the music: link, midi, fake or tap")
+	flag.StringVar(&midiDeviceFlag, "midi-device", "/dev/snd/midiC1D0", "raw midi device to read the midi clock from")
+	flag.StringVar(&tapAddrFlag, "tap-addr", "", "ip address and port on which the tap clock receives commands, sent with essaimtap")
+	flag.IntVar(&latencyFlag, "latency", 0, "latency of the output to compensate, in milliseconds (negative to delay the output)")
+	flag.StringVar(&rateFlag, "rate", "1/16", "rate at which steps are played: 1/4, 1/8, 1/8t, 1/16, 1/16t, 1/32, half or double")
+	flag.Float64Var(&swingFlag, "swing", 0, "fraction of a step by which odd steps are delayed, between 0 and 1")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to accept unauthenticated ones")
+	flag.DurationVar(&authWindowFlag, "auth-window", 0, "largest clock difference accepted with a new sender of authenticated messages, none to not compare clocks, which needs them synchronized")
 }
 
 func main() {
@@ -42,21 +61,59 @@ func main() {
 		}
 	}
 
-	k, err := depthstream.NewClient(netip.MustParseAddrPort(streamAddrFlag))
+	k, err := depthstream.NewClient(netip.MustParseAddrPort(streamAddrFlag), transport.UDPConfig{Interface: iface})
 	if err != nil {
 		log.Fatalf("could not not create kinect client: %s", err)
 	}
 
-	linkClock := clock.NewLinkClock(120.0)
-	defer linkClock.Close()
+	auth := protocol.Auth{
+		Key:    []byte(authKeyFlag),
+		Window: authWindowFlag,
+	}
+
+	var tapAddr netip.AddrPort
+	if tapAddrFlag != "" {
+		tapAddr, err = netip.ParseAddrPort(tapAddrFlag)
+		if err != nil {
+			log.Fatalf("could not parse tap address: %s", err)
+		}
+	}
+
+	src, err := clock.NewSource(clock.SourceConfig{
+		Kind:       clockFlag,
+		BPM:        120.0,
+		MIDIDevice: midiDeviceFlag,
+		TapAddr:    tapAddr,
+		Auth:       auth,
+		OnError: func(err error) {
+			fmt.Printf("clock stopped: %s\n", err)
+		},
+	})
+	if err != nil {
+		log.Fatalf("could not create clock: %s", err)
+	}
+	defer src.Close()
+
+	rate, err := clock.ParseRate(rateFlag)
+	if err != nil {
+		log.Fatalf("could not parse rate: %s", err)
+	}
+
+	latency := time.Duration(latencyFlag) * time.Millisecond
+	clk := clock.WithRate(clock.WithLatency(src, latency), rate, swingFlag)
+
+	conn, err := transport.OpenUDP(netip.MustParseAddrPort(addrFlag), transport.UDPConfig{Interface: iface})
+	if err != nil {
+		log.Fatalf("could not open transport: %s", err)
+	}
 
-	c, err := client.New(linkClock, 16, netip.MustParseAddrPort(addrFlag), k.RenderImage, channelFlag, iface)
+	c, err := client.New(clk, 16, conn, k.RenderImage, channelFlag, auth)
 	if err != nil {
 		log.Fatalf("could not create mikro controller: %s", err)
 	}
 	defer c.Close()
 
-	linkClock.Start()
+	src.Start()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -4,26 +4,58 @@ import (
 	"context"
 	"flag"
 	"log"
+	"net"
 	"net/netip"
+	"os"
 
 	"essaim.dev/essaim/clock"
 	"essaim.dev/essaim/depthstream"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 )
 
 var (
-	addrFlag       string
-	streamAddrFlag string
+	addrFlag              string
+	streamAddrFlag        string
+	announceAddrFlag      string
+	ifaceFlag             string
+	multicastTTLFlag      int
+	multicastLoopbackFlag bool
+	authKeyFlag           string
 )
 
 func init() {
 	flag.StringVar(&addrFlag, "addr", "224.76.78.75:20809", "ip address and port used to send instructions")
 	flag.StringVar(&streamAddrFlag, "stream-addr", "224.76.78.75:20810", "ip address and port used to send instructions")
+	flag.StringVar(&announceAddrFlag, "announce-addr", "224.2.2.3:9999", "ip address and port on which the server announces itself to the controller")
+	flag.StringVar(&ifaceFlag, "interface", "", "network interface used for multicast, chosen by the system if empty")
+	flag.IntVar(&multicastTTLFlag, "multicast-ttl", 1, "number of routers the multicast messages may go through, plus one")
+	flag.BoolVar(&multicastLoopbackFlag, "multicast-loopback", true, "whether the multicast messages sent are also received on this host")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to send them unauthenticated")
 }
 
 func main() {
 	flag.Parse()
 
-	k, err := depthstream.NewServer(netip.MustParseAddrPort(streamAddrFlag))
+	var iface *net.Interface
+	if ifaceFlag != "" {
+		var err error
+		iface, err = net.InterfaceByName(ifaceFlag)
+		if err != nil {
+			log.Fatalf("could not not parse interface: %s", err)
+		}
+	}
+
+	k, err := depthstream.NewServer(
+		netip.MustParseAddrPort(streamAddrFlag),
+		netip.MustParseAddrPort(announceAddrFlag),
+		transport.UDPConfig{
+			Interface:       iface,
+			TTL:             multicastTTLFlag,
+			DisableLoopback: !multicastLoopbackFlag,
+		},
+		protocol.Auth{Key: []byte(authKeyFlag)},
+	)
 	if err != nil {
 		log.Fatalf("could not not create kinect client: %s", err)
 	}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+
+	"essaim.dev/essaim/capture"
+	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
+)
+
+var (
+	addrFlag       string
+	ifaceFlag      string
+	outputFlag     string
+	clockFlag      string
+	midiDeviceFlag string
+	tapAddrFlag    string
+	authKeyFlag    string
+	transportFlag  string
+)
+
+func init() {
+	flag.StringVar(&addrFlag, "addr", "224.2.2.3:9999", "ip address and port on which instructions are sent")
+	flag.StringVar(&ifaceFlag, "interface", "", "network interface used for multicast, chosen by the system if empty")
+	flag.StringVar(&outputFlag, "output", "essaim.cap", "file to which the messages are recorded")
+	flag.StringVar(&clockFlag, "clock", clock.SourceLink, "clock source used to follow the music: link, midi, fake or tap")
+	flag.StringVar(&midiDeviceFlag, "midi-device", "/dev/snd/midiC1D0", "raw midi device to read the midi clock from")
+	flag.StringVar(&tapAddrFlag, "tap-addr", "", "ip address and port on which the tap clock receives commands, sent with essaimtap")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate tap commands, none to accept unauthenticated ones")
+	flag.StringVar(&transportFlag, "transport", transport.UDP, "transport of the messages: udp, to the multicast group at addr, or tcp, connecting to the controller at addr")
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("error: %s\n", err)
+	}
+}
+
+func run() error {
+	flag.Parse()
+
+	addr, err := netip.ParseAddrPort(addrFlag)
+	if err != nil {
+		return fmt.Errorf("could not not parse ip address: %w", err)
+	}
+
+	var iface *net.Interface
+	if ifaceFlag != "" {
+		iface, err = net.InterfaceByName(ifaceFlag)
+		if err != nil {
+			return fmt.Errorf("could not not parse interface: %w", err)
+		}
+	}
+
+	var tapAddr netip.AddrPort
+	if tapAddrFlag != "" {
+		tapAddr, err = netip.ParseAddrPort(tapAddrFlag)
+		if err != nil {
+			return fmt.Errorf("could not parse tap address: %w", err)
+		}
+	}
+
+	src, err := clock.NewSource(clock.SourceConfig{
+		Kind:       clockFlag,
+		BPM:        120.0,
+		MIDIDevice: midiDeviceFlag,
+		TapAddr:    tapAddr,
+		Auth:       protocol.Auth{Key: []byte(authKeyFlag)},
+		OnError: func(err error) {
+			fmt.Printf("clock stopped: %s\n", err)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create clock: %w", err)
+	}
+	defer src.Close()
+
+	f, err := os.Create(outputFlag)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+	defer f.Close()
+
+	w, err := capture.NewWriter(f)
+	if err != nil {
+		return err
+	}
+
+	conn, err := transport.Dial(transportFlag, addr, transport.UDPConfig{Interface: iface})
+	if err != nil {
+		return fmt.Errorf("could not open transport: %w", err)
+	}
+	defer conn.Close()
+
+	r := capture.NewRecorder(src, conn, w)
+	r.SetOnRecordFunc(func(record capture.Record, frame protocol.Frame) {
+		fmt.Printf("%10s step %6d: %s from %08x, sequence %d\n",
+			record.Elapsed.Truncate(1e6), record.Step, frame.Header.Type, frame.Header.Sender, frame.Header.Sequence)
+	})
+	r.SetOnDiscardFunc(func(err error) {
+		fmt.Printf("discarding packet: %s\n", err)
+	})
+
+	src.Start()
+	if err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("could not record: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+
+	"essaim.dev/essaim/capture"
+	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
+)
+
+var (
+	addrFlag              string
+	ifaceFlag             string
+	multicastTTLFlag      int
+	multicastLoopbackFlag bool
+	inputFlag             string
+	syncFlag              string
+	loopFlag              bool
+	clockFlag             string
+	midiDeviceFlag        string
+	tapAddrFlag           string
+	authKeyFlag           string
+	transportFlag         string
+)
+
+func init() {
+	flag.StringVar(&addrFlag, "addr", "224.2.2.3:9999", "ip address and port used to send instructions")
+	flag.StringVar(&ifaceFlag, "interface", "", "network interface used for multicast, chosen by the system if empty")
+	flag.IntVar(&multicastTTLFlag, "multicast-ttl", 1, "number of routers the multicast messages may go through, plus one")
+	flag.BoolVar(&multicastLoopbackFlag, "multicast-loopback", true, "whether the multicast messages sent are also received on this host")
+	flag.StringVar(&inputFlag, "input", "essaim.cap", "file from which the recorded messages are replayed")
+	flag.StringVar(&syncFlag, "sync", capture.SyncTime, "how the messages are timed: time, as recorded, or step, on the steps of the clock")
+	flag.BoolVar(&loopFlag, "loop", false, "replay the recording again once finished")
+	flag.StringVar(&clockFlag, "clock", clock.SourceLink, "clock source used to follow the music when syncing on steps: link, midi, fake or tap")
+	flag.StringVar(&midiDeviceFlag, "midi-device", "/dev/snd/midiC1D0", "raw midi device to read the midi clock from")
+	flag.StringVar(&tapAddrFlag, "tap-addr", "", "ip address and port on which the tap clock receives commands, sent with essaimtap")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to send them unauthenticated")
+	flag.StringVar(&transportFlag, "transport", transport.UDP, "transport of the messages: udp, to the multicast group at addr, or tcp, listening for the nodes on addr")
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("error: %s\n", err)
+	}
+}
+
+func run() error {
+	flag.Parse()
+
+	addr, err := netip.ParseAddrPort(addrFlag)
+	if err != nil {
+		return fmt.Errorf("could not not parse ip address: %w", err)
+	}
+
+	var iface *net.Interface
+	if ifaceFlag != "" {
+		iface, err = net.InterfaceByName(ifaceFlag)
+		if err != nil {
+			return fmt.Errorf("could not not parse interface: %w", err)
+		}
+	}
+
+	auth := protocol.Auth{Key: []byte(authKeyFlag)}
+
+	var src clock.Source
+	if syncFlag == capture.SyncStep {
+		var tapAddr netip.AddrPort
+		if tapAddrFlag != "" {
+			tapAddr, err = netip.ParseAddrPort(tapAddrFlag)
+			if err != nil {
+				return fmt.Errorf("could not parse tap address: %w", err)
+			}
+		}
+
+		src, err = clock.NewSource(clock.SourceConfig{
+			Kind:       clockFlag,
+			BPM:        120.0,
+			MIDIDevice: midiDeviceFlag,
+			TapAddr:    tapAddr,
+			Auth:       auth,
+			OnError: func(err error) {
+				fmt.Printf("clock stopped: %s\n", err)
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("could not create clock: %w", err)
+		}
+		defer src.Close()
+		src.Start()
+	}
+
+	udpConfig := transport.UDPConfig{
+		Interface:       iface,
+		TTL:             multicastTTLFlag,
+		DisableLoopback: !multicastLoopbackFlag,
+	}
+
+	conn, err := transport.Listen(transportFlag, addr, udpConfig)
+	if err != nil {
+		return fmt.Errorf("could not open transport: %w", err)
+	}
+	defer conn.Close()
+
+	// The messages of the nodes are read and dropped, so that they do not
+	// pile up in the connection.
+	go discard(conn)
+
+	var clk clock.Clock
+	if src != nil {
+		clk = src
+	}
+
+	p, err := capture.NewPlayer(conn, syncFlag, clk, auth)
+	if err != nil {
+		return fmt.Errorf("could not create player: %w", err)
+	}
+	p.SetOnPlayFunc(func(record capture.Record, frame protocol.Frame) {
+		fmt.Printf("%10s step %6d: replaying %s, steps per channel %s\n",
+			record.Elapsed.Truncate(1e6), record.Step, frame.Header.Type, p.Summary())
+	})
+	p.SetOnSkipFunc(func(record capture.Record, err error) {
+		fmt.Printf("%10s step %6d: skipping record: %s\n", record.Elapsed.Truncate(1e6), record.Step, err)
+	})
+
+	for {
+		if err := replay(p); err != nil {
+			return err
+		}
+
+		if !loopFlag {
+			return nil
+		}
+	}
+}
+
+func replay(p *capture.Player) error {
+	f, err := os.Open(inputFlag)
+	if err != nil {
+		return fmt.Errorf("could not open input file: %w", err)
+	}
+	defer f.Close()
+
+	r, err := capture.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Play(context.Background(), r); err != nil {
+		return fmt.Errorf("could not replay: %w", err)
+	}
+
+	return nil
+}
+
+func discard(conn transport.Conn) {
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		if _, _, err := conn.ReadFrom(b); err != nil {
+			return
+		}
+	}
+}
@@ -10,22 +10,48 @@ import (
 	"log"
 	"net"
 	"net/netip"
+	"os"
+	"time"
 
 	"essaim.dev/essaim/client"
 	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 	"golang.org/x/exp/shiny/driver"
 )
 
 var (
-	addrFlag    string
-	ifaceFlag   string
-	channelFlag uint64
+	addrFlag              string
+	ifaceFlag             string
+	multicastTTLFlag      int
+	multicastLoopbackFlag bool
+	channelFlag           uint64
+	clockFlag             string
+	midiDeviceFlag        string
+	tapAddrFlag           string
+	latencyFlag           int
+	rateFlag              string
+	swingFlag             float64
+	authKeyFlag           string
+	authWindowFlag        time.Duration
+	transportFlag         string
 )
 
 func init() {
 	flag.StringVar(&addrFlag, "addr", "224.2.2.3:9999", "ip address and port used to send instructions")
 	flag.StringVar(&ifaceFlag, "interface", "", "")
+	flag.IntVar(&multicastTTLFlag, "multicast-ttl", 1, "number of routers the multicast messages may go through, plus one")
+	flag.BoolVar(&multicastLoopbackFlag, "multicast-loopback", true, "whether the multicast messages sent are also received on this host")
 	flag.Uint64Var(&channelFlag, "channel", 0, "")
+	flag.StringVar(&clockFlag, "clock", clock.SourceLink, "clock source used to follow the music: link, midi, fake or tap")
+	flag.StringVar(&midiDeviceFlag, "midi-device", "/dev/snd/midiC1D0", "raw midi device to read the midi clock from")
+	flag.StringVar(&tapAddrFlag, "tap-addr", "", "ip address and port on which the tap clock receives commands, sent with essaimtap")
+	flag.IntVar(&latencyFlag, "latency", 0, "latency of the output to compensate, in milliseconds (negative to delay the output)")
+	flag.StringVar(&rateFlag, "rate", "1/16", "rate at which steps are played: 1/4, 1/8, 1/8t, 1/16, 1/16t, 1/32, half or double")
+	flag.Float64Var(&swingFlag, "swing", 0, "fraction of a step by which odd steps are delayed, between 0 and 1")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to accept unauthenticated ones")
+	flag.DurationVar(&authWindowFlag, "auth-window", 0, "largest clock difference accepted with a new sender of authenticated messages, none to not compare clocks, which needs them synchronized")
+	flag.StringVar(&transportFlag, "transport", transport.UDP, "transport of the messages: udp, to the multicast group at addr, or tcp, connecting to the controller at addr")
 }
 
 func main() {
@@ -46,16 +72,60 @@ func main() {
 		log.Fatalf("could not not parse ip address: %s", err)
 	}
 
-	linkClock := clock.NewLinkClock(120.0)
-	defer linkClock.Close()
+	auth := protocol.Auth{
+		Key:    []byte(authKeyFlag),
+		Window: authWindowFlag,
+	}
+
+	var tapAddr netip.AddrPort
+	if tapAddrFlag != "" {
+		tapAddr, err = netip.ParseAddrPort(tapAddrFlag)
+		if err != nil {
+			log.Fatalf("could not parse tap address: %s", err)
+		}
+	}
+
+	src, err := clock.NewSource(clock.SourceConfig{
+		Kind:       clockFlag,
+		BPM:        120.0,
+		MIDIDevice: midiDeviceFlag,
+		TapAddr:    tapAddr,
+		Auth:       auth,
+		OnError: func(err error) {
+			fmt.Printf("clock stopped: %s\n", err)
+		},
+	})
+	if err != nil {
+		log.Fatalf("could not create clock: %s", err)
+	}
+	defer src.Close()
+
+	rate, err := clock.ParseRate(rateFlag)
+	if err != nil {
+		log.Fatalf("could not parse rate: %s", err)
+	}
+
+	latency := time.Duration(latencyFlag) * time.Millisecond
+	clk := clock.WithRate(clock.WithLatency(src, latency), rate, swingFlag)
+
+	udpConfig := transport.UDPConfig{
+		Interface:       iface,
+		TTL:             multicastTTLFlag,
+		DisableLoopback: !multicastLoopbackFlag,
+	}
+
+	conn, err := transport.Dial(transportFlag, addr, udpConfig)
+	if err != nil {
+		log.Fatalf("could not open transport: %s", err)
+	}
 
-	c, err := client.New(linkClock, 16, addr, render, channelFlag, iface)
+	c, err := client.New(clk, 16, conn, render, channelFlag, auth)
 	if err != nil {
 		log.Fatalf("could not create mikro controller: %s", err)
 	}
 	defer c.Close()
 
-	linkClock.Start()
+	src.Start()
 
 	clientStopped := make(chan error, 1)
 	go func() {
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
+)
+
+var (
+	addrFlag    string
+	ifaceFlag   string
+	authKeyFlag string
+)
+
+func init() {
+	flag.StringVar(&addrFlag, "addr", "", "ip address and port on which the tap clocks receive commands")
+	flag.StringVar(&ifaceFlag, "interface", "", "network interface used for multicast, chosen by the system if empty")
+	flag.StringVar(&authKeyFlag, "auth-key", os.Getenv("ESSAIM_AUTH_KEY"), "key shared by the nodes to authenticate messages, none to send them unauthenticated")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] tap | resync | nudge <ms> | tempo <bpm>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("error: %s\n", err)
+	}
+}
+
+func run() error {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+
+	addr, err := netip.ParseAddrPort(addrFlag)
+	if err != nil {
+		return fmt.Errorf("could not not parse ip address: %w", err)
+	}
+
+	var iface *net.Interface
+	if ifaceFlag != "" {
+		iface, err = net.InterfaceByName(ifaceFlag)
+		if err != nil {
+			return fmt.Errorf("could not not parse interface: %w", err)
+		}
+	}
+
+	conn, err := transport.DialUDP(addr, transport.UDPConfig{Interface: iface})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sender := protocol.NewSender(conn, protocol.Auth{Key: []byte(authKeyFlag)})
+	if err := sender.Send(essaimbp.MESSAGE_TYPE_TAP, []byte(strings.Join(flag.Args(), " "))); err != nil {
+		return fmt.Errorf("could not send tap command: %w", err)
+	}
+
+	return nil
+}
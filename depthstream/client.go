@@ -7,15 +7,15 @@ import (
 	"image"
 	"image/color"
 	"io"
-	"net"
 	"net/netip"
 	"sync"
 
+	"essaim.dev/essaim/transport"
 	"github.com/klauspost/compress/zstd"
 )
 
 type Client struct {
-	conn *net.UDPConn
+	conn *transport.UDPConn
 
 	decoder *zstd.Decoder
 
@@ -23,13 +23,11 @@ type Client struct {
 	binaryImage   []byte
 }
 
-func NewClient(addr netip.AddrPort) (*Client, error) {
-	conn, err := net.ListenUDP("udp4", net.UDPAddrFromAddrPort(addr))
-	// conn, err := net.ListenMulticastUDP("udp4", nil, net.UDPAddrFromAddrPort(addr))
+func NewClient(addr netip.AddrPort, udpConfig transport.UDPConfig) (*Client, error) {
+	conn, err := transport.OpenUDP(addr, udpConfig)
 	if err != nil {
-		return nil, fmt.Errorf("could not listen on multicast address: %w", err)
+		return nil, err
 	}
-	conn.SetReadBuffer(binaryImageSize + 1000)
 
 	decoder, err := zstd.NewReader(nil)
 	if err != nil {
@@ -55,7 +53,7 @@ func (c *Client) Run(ctx context.Context) error {
 		case <-ctx.Done():
 			return fmt.Errorf("context canceled: %w", ctx.Err())
 		default:
-			n, _, err := c.conn.ReadFromUDP(b)
+			n, _, err := c.conn.ReadFrom(b)
 			if err != nil && errors.Is(err, io.EOF) {
 				return fmt.Errorf("connection closed: %w", err)
 			}
@@ -6,14 +6,23 @@ import (
 	"net"
 	"net/netip"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"essaim.dev/essaim/api/essaimbp"
 	"essaim.dev/essaim/freenect"
+	"essaim.dev/essaim/presence"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 	"github.com/klauspost/compress/zstd"
 )
 
 const (
 	binaryImageSize = 640 * 480 / 8
+
+	// depthFrameTimeout is the delay without depth frame after which the
+	// server reports itself as degraded.
+	depthFrameTimeout = time.Duration(time.Second)
 )
 
 type Server struct {
@@ -26,12 +35,18 @@ type Server struct {
 	depthThreshold   uint16
 
 	encoder *zstd.Encoder
+
+	announceConn *net.UDPConn
+	announcer    *presence.Announcer
+	frames       atomic.Int64
+	lastFrameAt  atomic.Int64
+	writeFailed  atomic.Bool
 }
 
-func NewServer(addr netip.AddrPort) (*Server, error) {
-	conn, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(addr))
+func NewServer(addr netip.AddrPort, announceAddr netip.AddrPort, udpConfig transport.UDPConfig, auth protocol.Auth) (*Server, error) {
+	conn, err := transport.DialUDP(addr, udpConfig)
 	if err != nil {
-		return nil, fmt.Errorf("could not dial udp address: %w", err)
+		return nil, err
 	}
 	conn.SetWriteBuffer(binaryImageSize + 1000)
 
@@ -54,17 +69,29 @@ func NewServer(addr netip.AddrPort) (*Server, error) {
 		return nil, fmt.Errorf("could not create encoder: %w", err)
 	}
 
-	return &Server{
+	announceConn, err := transport.DialUDP(announceAddr, udpConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not dial announce address: %w", err)
+	}
+	announcer := presence.NewAnnouncer(announceConn, essaimbp.NODE_ROLE_DEPTH_SERVER, 0, auth)
+
+	s := &Server{
 		conn:           conn,
 		kinectCtx:      &fctx,
 		kinectDevice:   &device,
 		depthThreshold: 1100,
 		encoder:        encoder,
-	}, nil
+		announceConn:   announceConn,
+		announcer:      announcer,
+	}
+	announcer.SetStatusFunc(s.status)
+
+	return s, nil
 }
 
 func (s *Server) Close() error {
 	s.encoder.Close()
+	s.announceConn.Close()
 	return s.conn.Close()
 }
 
@@ -76,6 +103,8 @@ func (s *Server) SetDepthThreshold(threshold uint16) {
 }
 
 func (s *Server) Run(ctx context.Context) error {
+	go s.announcer.Run(ctx)
+
 	s.kinectDevice.SetDepthCallback(s.depthCallback)
 
 	if err := s.kinectDevice.SetLED(freenect.LEDColorBlinkGreen); err != nil {
@@ -102,11 +131,33 @@ func (s *Server) depthCallback(device *freenect.Device, depth []uint16, timestam
 
 	// encoded := s.encoder.EncodeAll(binaryImage, make([]byte, 0, len(binaryImage)))
 
-	if n, err := s.conn.Write(binaryImage); err != nil {
+	s.frames.Add(1)
+	s.lastFrameAt.Store(time.Now().UnixNano())
+
+	n, err := s.conn.Write(binaryImage)
+	s.writeFailed.Store(err != nil)
+	if err != nil {
 		fmt.Println(n, err)
 	}
 }
 
+// status reports the number of depth frames as the step of the server, which
+// has no clock.
+func (s *Server) status() presence.Status {
+	health := essaimbp.NODE_HEALTH_OK
+	if time.Since(time.Unix(0, s.lastFrameAt.Load())) > depthFrameTimeout {
+		health = essaimbp.NODE_HEALTH_DEGRADED
+	}
+	if s.writeFailed.Load() {
+		health = essaimbp.NODE_HEALTH_FAILING
+	}
+
+	return presence.Status{
+		Step:   s.frames.Load(),
+		Health: health,
+	}
+}
+
 func (s *Server) depthToBinaryImage(depth []uint16) []byte {
 	output := make([]byte, (len(depth)+7)/8)
 
@@ -0,0 +1,424 @@
+package dmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ArtNetPort = 6454
+
+	artNetVersion = 14
+
+	opPoll      = 0x2000
+	opPollReply = 0x2100
+	opDMX       = 0x5000
+
+	artPollReplySize = 239
+	// artPollInterval is the delay between two polls of the nodes, and
+	// artNodeTimeout the one after which a node which did not reply is
+	// forgotten.
+	artPollInterval = time.Duration(time.Second * 3)
+	artNodeTimeout  = 3 * artPollInterval
+
+	styleController = 0x01
+	portInput       = 0x40
+)
+
+var artNetID = []byte("Art-Net\x00")
+
+// artBroadcast is the address to which the polls, and the universes of no
+// known node, are sent when no address is configured.
+var artBroadcast = netip.AddrPortFrom(netip.AddrFrom4([4]byte{255, 255, 255, 255}), ArtNetPort)
+
+var (
+	ErrBadPortAddress = errors.New("art-net port address is out of range")
+)
+
+// ArtNetNode is a node which replied to the polls of an output.
+type ArtNetNode struct {
+	Addr netip.Addr
+	Name string
+	// Universes are the port addresses of the outputs of the node.
+	Universes []uint16
+	LastSeen  time.Time
+}
+
+// ArtNet is an output sending its universes to Art-Net nodes, at its own
+// refresh rate. The universes are sent to the configured address, either a
+// node or a broadcast address, or to the nodes outputting them if none, and
+// broadcast while no node is known to output them.
+type ArtNet struct {
+	conn *net.UDPConn
+	addr netip.AddrPort
+
+	// portAddress is the port address of the first universe.
+	portAddress uint16
+	rate        time.Duration
+
+	frames   *frames
+	sequence byte
+
+	nodes   map[netip.Addr]*ArtNetNode
+	nodesMu sync.RWMutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// OpenArtNet returns an output of the given number of universes, starting at
+// the port address made of net, subnet and universe.
+func OpenArtNet(addr netip.AddrPort, net, subnet, universe, universes int, rate time.Duration) (*ArtNet, error) {
+	if net < 0 || net > 0x7f || subnet < 0 || subnet > 0xf || universe < 0 || universe > 0xf {
+		return nil, fmt.Errorf("%w: net %d, subnet %d, universe %d", ErrBadPortAddress, net, subnet, universe)
+	}
+
+	portAddress := net<<8 | subnet<<4 | universe
+	if portAddress+universes-1 > 0x7fff {
+		return nil, fmt.Errorf("%w: %d universes from %d", ErrBadPortAddress, universes, portAddress)
+	}
+
+	if rate <= 0 {
+		rate = DefaultRate
+	}
+
+	conn, err := listenArtNet()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ArtNet{
+		conn:        conn,
+		addr:        addr,
+		portAddress: uint16(portAddress),
+		rate:        rate,
+		frames:      newFrames(universes),
+		nodes:       make(map[netip.Addr]*ArtNetNode),
+		done:        make(chan struct{}),
+	}
+
+	go a.refresh()
+	go a.poll()
+	go a.listen()
+
+	return a, nil
+}
+
+// listenArtNet listens on the Art-Net port to answer polls and discover the
+// nodes, or on any port when it is taken, only sending then.
+func listenArtNet() (*net.UDPConn, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: ArtNetPort})
+	if err == nil {
+		return conn, nil
+	}
+	fmt.Printf("could not listen on art-net port, nodes will not be discovered: %s\n", err)
+
+	conn, err = net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on udp address: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (a *ArtNet) SetChannel(universe int, id int, value byte) error {
+	return a.SetChannels(universe, id, []byte{value})
+}
+
+func (a *ArtNet) SetChannels(universe int, start int, values []byte) error {
+	return a.frames.set(universe, start, values)
+}
+
+// Render makes the channels set so far those sent on the next refreshes.
+func (a *ArtNet) Render() error {
+	a.frames.render()
+
+	return nil
+}
+
+func (a *ArtNet) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.done)
+	})
+
+	return a.conn.Close()
+}
+
+func (a *ArtNet) Universes() int {
+	return a.frames.universes()
+}
+
+// Nodes returns the nodes which replied to the polls recently, by address.
+func (a *ArtNet) Nodes() []ArtNetNode {
+	a.nodesMu.RLock()
+	defer a.nodesMu.RUnlock()
+
+	nodes := make([]ArtNetNode, 0, len(a.nodes))
+	for _, node := range a.nodes {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Addr.Less(nodes[j].Addr)
+	})
+
+	return nodes
+}
+
+func (a *ArtNet) refresh() {
+	t := time.NewTicker(a.rate)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-t.C:
+		}
+
+		if err := a.send(); err != nil {
+			fmt.Printf("could not send art-net universes: %s\n", err)
+		}
+	}
+}
+
+func (a *ArtNet) send() error {
+	// The sequence starts again at 1, as 0 disables reordering.
+	a.sequence++
+	if a.sequence == 0 {
+		a.sequence = 1
+	}
+
+	var errs []error
+	a.frames.each(func(universe int, channels []byte) {
+		portAddress := a.portAddress + uint16(universe)
+		packet := artDMX(a.sequence, portAddress, channels)
+
+		for _, addr := range a.destinations(portAddress) {
+			if _, err := a.conn.WriteToUDPAddrPort(packet, addr); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// destinations returns the addresses to which a universe is sent.
+func (a *ArtNet) destinations(portAddress uint16) []netip.AddrPort {
+	if a.addr.IsValid() {
+		return []netip.AddrPort{a.addr}
+	}
+
+	a.nodesMu.RLock()
+	defer a.nodesMu.RUnlock()
+
+	var addrs []netip.AddrPort
+	for _, node := range a.nodes {
+		for _, universe := range node.Universes {
+			if universe == portAddress {
+				addrs = append(addrs, netip.AddrPortFrom(node.Addr, ArtNetPort))
+				break
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		return []netip.AddrPort{artBroadcast}
+	}
+
+	return addrs
+}
+
+// poll regularly asks the nodes of the network to reply, so that they are
+// discovered and forgotten once gone.
+func (a *ArtNet) poll() {
+	t := time.NewTicker(artPollInterval)
+	defer t.Stop()
+
+	broadcast := artBroadcast
+	if a.addr.IsValid() {
+		broadcast = netip.AddrPortFrom(a.addr.Addr(), ArtNetPort)
+	}
+
+	for {
+		if _, err := a.conn.WriteToUDPAddrPort(artPoll(), broadcast); err != nil {
+			fmt.Printf("could not poll art-net nodes: %s\n", err)
+		}
+
+		select {
+		case <-a.done:
+			return
+		case now := <-t.C:
+			a.forgetNodes(now)
+		}
+	}
+}
+
+func (a *ArtNet) forgetNodes(now time.Time) {
+	a.nodesMu.Lock()
+	defer a.nodesMu.Unlock()
+
+	for addr, node := range a.nodes {
+		if now.Sub(node.LastSeen) > artNodeTimeout {
+			delete(a.nodes, addr)
+		}
+	}
+}
+
+func (a *ArtNet) listen() {
+	b := make([]byte, 1500)
+	for {
+		n, from, err := a.conn.ReadFromUDPAddrPort(b)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Printf("could not read art-net packet: %s\n", err)
+			continue
+		}
+
+		packet := b[:n]
+		if len(packet) < 10 || !bytes.Equal(packet[:8], artNetID) {
+			continue
+		}
+
+		switch binary.LittleEndian.Uint16(packet[8:]) {
+		case opPoll:
+			if err := a.replyPoll(from); err != nil {
+				fmt.Printf("could not reply to art-net poll: %s\n", err)
+			}
+
+		case opPollReply:
+			a.updateNode(packet, time.Now())
+		}
+	}
+}
+
+func (a *ArtNet) replyPoll(from netip.AddrPort) error {
+	// The address of the output is the one through which the poller is
+	// reached.
+	probe, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(from))
+	if err != nil {
+		return err
+	}
+	local := probe.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap()
+	probe.Close()
+
+	_, err = a.conn.WriteToUDPAddrPort(a.pollReply(local), netip.AddrPortFrom(from.Addr(), ArtNetPort))
+	return err
+}
+
+func (a *ArtNet) updateNode(packet []byte, now time.Time) {
+	if len(packet) < 194 {
+		return
+	}
+
+	addr := netip.AddrFrom4([4]byte(packet[10:14]))
+	node := &ArtNetNode{
+		Addr:     addr,
+		Name:     string(bytes.TrimRight(packet[26:44], "\x00")),
+		LastSeen: now,
+	}
+
+	// Nodes with more than four ports send a reply for every group of four,
+	// whose outputs are merged.
+	a.nodesMu.Lock()
+	defer a.nodesMu.Unlock()
+
+	if known, ok := a.nodes[addr]; ok && now.Sub(known.LastSeen) < artPollInterval {
+		node.Universes = known.Universes
+	}
+
+	base := uint16(packet[18]&0x7f)<<8 | uint16(packet[19]&0x0f)<<4
+	ports := min(int(packet[173]), 4)
+	for idx := range ports {
+		// Only the ports outputting DMX from Art-Net are of interest.
+		if packet[174+idx]&0x80 == 0 {
+			continue
+		}
+		universe := base | uint16(packet[190+idx]&0x0f)
+		if !containsUniverse(node.Universes, universe) {
+			node.Universes = append(node.Universes, universe)
+		}
+	}
+
+	a.nodes[addr] = node
+}
+
+func containsUniverse(universes []uint16, universe uint16) bool {
+	for _, u := range universes {
+		if u == universe {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *ArtNet) pollReply(local netip.Addr) []byte {
+	b := make([]byte, artPollReplySize)
+
+	copy(b, artNetID)
+	binary.LittleEndian.PutUint16(b[8:], opPollReply)
+	if local.Is4() {
+		ip := local.As4()
+		copy(b[10:14], ip[:])
+	}
+	binary.LittleEndian.PutUint16(b[14:], ArtNetPort)
+	b[18] = byte(a.portAddress >> 8 & 0x7f)
+	b[19] = byte(a.portAddress >> 4 & 0x0f)
+	copy(b[26:44], "essaim")
+	copy(b[44:108], "essaim dmx node")
+
+	// The first universes are advertised as inputs onto the network, as
+	// long as they share the net and subnet of the first one.
+	ports := 0
+	for idx := range min(a.Universes(), 4) {
+		portAddress := a.portAddress + uint16(idx)
+		if portAddress>>4 != a.portAddress>>4 {
+			break
+		}
+		b[174+idx] = portInput
+		b[178+idx] = 0x80
+		b[186+idx] = byte(portAddress & 0x0f)
+		ports++
+	}
+	b[173] = byte(ports)
+	b[200] = styleController
+
+	return b
+}
+
+func artPoll() []byte {
+	b := make([]byte, 14)
+
+	copy(b, artNetID)
+	binary.LittleEndian.PutUint16(b[8:], opPoll)
+	binary.BigEndian.PutUint16(b[10:], artNetVersion)
+
+	return b
+}
+
+// artDMX returns the ArtDmx packet of a universe, whose length must be even.
+func artDMX(sequence byte, portAddress uint16, channels []byte) []byte {
+	length := len(channels) + len(channels)%2
+
+	b := make([]byte, 18+length)
+	copy(b, artNetID)
+	binary.LittleEndian.PutUint16(b[8:], opDMX)
+	binary.BigEndian.PutUint16(b[10:], artNetVersion)
+	b[12] = sequence
+	b[14] = byte(portAddress)
+	b[15] = byte(portAddress >> 8 & 0x7f)
+	binary.BigEndian.PutUint16(b[16:], uint16(length))
+	copy(b[18:], channels)
+
+	return b
+}
@@ -0,0 +1,155 @@
+package dmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestArtNet returns an output sending its universes to a local listener,
+// without refreshing them on its own.
+func newTestArtNet(t *testing.T, portAddress uint16, universes int) (*ArtNet, *net.UDPConn) {
+	t.Helper()
+
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	a := &ArtNet{
+		conn:        conn,
+		addr:        listener.LocalAddr().(*net.UDPAddr).AddrPort(),
+		portAddress: portAddress,
+		rate:        DefaultRate,
+		frames:      newFrames(universes),
+		nodes:       make(map[netip.Addr]*ArtNetNode),
+		done:        make(chan struct{}),
+	}
+
+	return a, listener
+}
+
+func readPacket(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	b := make([]byte, 1500)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b[:n]
+}
+
+func TestArtNetSend(t *testing.T) {
+	// Net 1, subnet 2 and universe 15, the second universe being the first of
+	// the next subnet.
+	a, listener := newTestArtNet(t, 0x12f, 2)
+
+	if err := a.SetChannels(0, 1, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetChannel(1, MaxChannel, 4); err != nil {
+		t.Fatal(err)
+	}
+	a.Render()
+
+	// The sequence wraps around to 1, as 0 disables reordering.
+	a.sequence = 254
+	for _, sequence := range []byte{255, 1} {
+		if err := a.send(); err != nil {
+			t.Fatal(err)
+		}
+
+		for universe, portAddress := range []uint16{0x12f, 0x130} {
+			packet := readPacket(t, listener)
+
+			if len(packet) != 18+MaxChannel {
+				t.Fatalf("got a packet of %d bytes, want %d", len(packet), 18+MaxChannel)
+			}
+			if !bytes.Equal(packet[:8], artNetID) {
+				t.Fatalf("got id %q, want %q", packet[:8], artNetID)
+			}
+			if op := binary.LittleEndian.Uint16(packet[8:]); op != opDMX {
+				t.Fatalf("got opcode %#x, want %#x", op, opDMX)
+			}
+			if version := binary.BigEndian.Uint16(packet[10:]); version != artNetVersion {
+				t.Fatalf("got version %d, want %d", version, artNetVersion)
+			}
+			if packet[12] != sequence {
+				t.Fatalf("got sequence %d, want %d", packet[12], sequence)
+			}
+			// The port address is sent as its low byte followed by its net.
+			if got := uint16(packet[15])<<8 | uint16(packet[14]); got != portAddress {
+				t.Fatalf("got port address %#x for universe %d, want %#x", got, universe, portAddress)
+			}
+			if length := binary.BigEndian.Uint16(packet[16:]); length != MaxChannel {
+				t.Fatalf("got length %d, want %d", length, MaxChannel)
+			}
+		}
+	}
+
+	a.Render()
+	if err := a.send(); err != nil {
+		t.Fatal(err)
+	}
+	if packet := readPacket(t, listener); !bytes.Equal(packet[18:21], []byte{1, 2, 3}) {
+		t.Fatalf("got channels % x, want 01 02 03", packet[18:21])
+	}
+	if packet := readPacket(t, listener); packet[len(packet)-1] != 4 {
+		t.Fatalf("got last channel %d, want 4", packet[len(packet)-1])
+	}
+}
+
+func TestArtDMXPadding(t *testing.T) {
+	// The number of channels of a packet is even, padded with a zero.
+	packet := artDMX(1, 0, []byte{1, 2, 3})
+
+	if length := binary.BigEndian.Uint16(packet[16:]); length != 4 {
+		t.Fatalf("got length %d, want 4", length)
+	}
+	if !bytes.Equal(packet[18:], []byte{1, 2, 3, 0}) {
+		t.Fatalf("got channels % x, want 01 02 03 00", packet[18:])
+	}
+}
+
+func TestArtNetDestinations(t *testing.T) {
+	a := &ArtNet{
+		nodes: make(map[netip.Addr]*ArtNetNode),
+	}
+
+	// The universes of no known node are broadcast.
+	if got := a.destinations(1); !slices.Equal(got, []netip.AddrPort{artBroadcast}) {
+		t.Fatalf("got destinations %v, want %v", got, artBroadcast)
+	}
+
+	node := netip.MustParseAddr("10.0.0.2")
+	a.nodes[node] = &ArtNetNode{Addr: node, Universes: []uint16{0, 1}}
+
+	want := []netip.AddrPort{netip.AddrPortFrom(node, ArtNetPort)}
+	if got := a.destinations(1); !slices.Equal(got, want) {
+		t.Fatalf("got destinations %v, want %v", got, want)
+	}
+	if got := a.destinations(2); !slices.Equal(got, []netip.AddrPort{artBroadcast}) {
+		t.Fatalf("got destinations %v, want %v", got, artBroadcast)
+	}
+
+	// The configured address takes precedence over the nodes.
+	a.addr = netip.MustParseAddrPort("10.0.0.255:6454")
+	if got := a.destinations(1); !slices.Equal(got, []netip.AddrPort{a.addr}) {
+		t.Fatalf("got destinations %v, want %v", got, a.addr)
+	}
+}
@@ -0,0 +1,290 @@
+package dmx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ziutek/ftdi"
+)
+
+const (
+	enttecStart = 0x7e
+	enttecEnd   = 0xe7
+
+	labelGetParams    = 3
+	labelSetParams    = 4
+	labelSendDMX      = 6
+	labelSerialNumber = 10
+
+	// enttecUnit is the unit of the break and mark after break times of the
+	// widget.
+	enttecUnit = time.Duration(time.Nanosecond * 10670)
+	// enttecMaxData is the largest message accepted by the widget.
+	enttecMaxData = 600
+
+	enttecTimeout = time.Duration(time.Second)
+)
+
+var (
+	ErrBadMessage    = errors.New("invalid widget message")
+	ErrTimeout       = errors.New("widget did not reply in time")
+	ErrBadParameters = errors.New("widget parameters are out of range")
+)
+
+// WidgetParams are the timings of the DMX packets sent by a widget.
+type WidgetParams struct {
+	// Firmware is the version of the firmware of the widget, ignored when
+	// setting the parameters.
+	Firmware uint16
+	// BreakTime is the duration of the break, from 96µs to 1.36ms.
+	BreakTime time.Duration
+	// MABTime is the duration of the mark after break, from 10.67µs to
+	// 1.36ms.
+	MABTime time.Duration
+	// Rate is the number of packets sent per second, from 1 to 40, or 0 to
+	// send them as fast as possible.
+	Rate int
+}
+
+// EnttecPro is an output driving a single universe through an Enttec DMX USB
+// Pro widget, which times the packets itself.
+type EnttecPro struct {
+	port   io.ReadWriteCloser
+	portMu sync.Mutex
+
+	// frame is the packet of the universe, starting with the null start code.
+	frame   []byte
+	frameMu sync.Mutex
+}
+
+// OpenEnttecPro opens the first widget connected.
+func OpenEnttecPro() (*EnttecPro, error) {
+	dev, err := ftdi.OpenFirst(vendorID, productID, ftdi.ChannelAny)
+	if err != nil {
+		return nil, fmt.Errorf("could not open ftdi device: %w", err)
+	}
+
+	if err := dev.Reset(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("could not reset ftdi device: %w", err)
+	}
+
+	if err := dev.PurgeBuffers(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("could not purge buffers of ftdi device: %w", err)
+	}
+
+	return NewEnttecPro(dev), nil
+}
+
+// NewEnttecPro returns an output exchanging the messages of the widget
+// through the given serial port, whose reads must return nothing rather than
+// block while no data is available, as those of the ftdi devices do, for the
+// requests to time out.
+func NewEnttecPro(port io.ReadWriteCloser) *EnttecPro {
+	return &EnttecPro{
+		port:  port,
+		frame: make([]byte, 1+MaxChannel),
+	}
+}
+
+func (e *EnttecPro) SetChannel(universe int, id int, value byte) error {
+	return e.SetChannels(universe, id, []byte{value})
+}
+
+func (e *EnttecPro) SetChannels(universe int, start int, values []byte) error {
+	if err := checkRange(e.Universes(), universe, start, len(values)); err != nil {
+		return err
+	}
+
+	e.frameMu.Lock()
+	defer e.frameMu.Unlock()
+
+	copy(e.frame[start:], values)
+
+	return nil
+}
+
+// Render hands the universe to the widget, which sends it until the next
+// render.
+func (e *EnttecPro) Render() error {
+	e.frameMu.Lock()
+	frame := append([]byte(nil), e.frame...)
+	e.frameMu.Unlock()
+
+	e.portMu.Lock()
+	defer e.portMu.Unlock()
+
+	if err := writeMessage(e.port, labelSendDMX, frame); err != nil {
+		return fmt.Errorf("could not send dmx packet to widget: %w", err)
+	}
+
+	return nil
+}
+
+func (e *EnttecPro) Close() error {
+	return e.port.Close()
+}
+
+func (e *EnttecPro) Universes() int {
+	return 1
+}
+
+// Params returns the parameters of the widget.
+func (e *EnttecPro) Params() (WidgetParams, error) {
+	// No user configuration is requested along the parameters.
+	data, err := e.request(labelGetParams, []byte{0, 0})
+	if err != nil {
+		return WidgetParams{}, fmt.Errorf("could not get widget parameters: %w", err)
+	}
+
+	if len(data) < 5 {
+		return WidgetParams{}, fmt.Errorf("%w: %d bytes of parameters", ErrBadMessage, len(data))
+	}
+
+	return WidgetParams{
+		Firmware:  binary.LittleEndian.Uint16(data),
+		BreakTime: time.Duration(data[2]) * enttecUnit,
+		MABTime:   time.Duration(data[3]) * enttecUnit,
+		Rate:      int(data[4]),
+	}, nil
+}
+
+// SetParams sets the timings of the DMX packets sent by the widget, until it
+// is unplugged.
+func (e *EnttecPro) SetParams(p WidgetParams) error {
+	breakTime := int((p.BreakTime + enttecUnit - 1) / enttecUnit)
+	mabTime := int((p.MABTime + enttecUnit - 1) / enttecUnit)
+	if breakTime < 9 || breakTime > 127 || mabTime < 1 || mabTime > 127 || p.Rate < 0 || p.Rate > 40 {
+		return fmt.Errorf("%w: %+v", ErrBadParameters, p)
+	}
+
+	e.portMu.Lock()
+	defer e.portMu.Unlock()
+
+	if err := writeMessage(e.port, labelSetParams, []byte{0, 0, byte(breakTime), byte(mabTime), byte(p.Rate)}); err != nil {
+		return fmt.Errorf("could not set widget parameters: %w", err)
+	}
+
+	return nil
+}
+
+// SerialNumber returns the serial number of the widget.
+func (e *EnttecPro) SerialNumber() (uint32, error) {
+	data, err := e.request(labelSerialNumber, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not get widget serial number: %w", err)
+	}
+
+	if len(data) < 4 {
+		return 0, fmt.Errorf("%w: %d bytes of serial number", ErrBadMessage, len(data))
+	}
+
+	// The serial number is written in binary-coded decimal, least significant
+	// byte first.
+	var serial uint32
+	for idx := 3; idx >= 0; idx-- {
+		serial = serial*100 + uint32(data[idx]>>4)*10 + uint32(data[idx]&0x0f)
+	}
+
+	return serial, nil
+}
+
+// request sends a message to the widget and returns the data of its reply,
+// which has the same label.
+func (e *EnttecPro) request(label byte, data []byte) ([]byte, error) {
+	e.portMu.Lock()
+	defer e.portMu.Unlock()
+
+	if err := writeMessage(e.port, label, data); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(enttecTimeout)
+	for {
+		replyLabel, reply, err := readMessage(&timeoutReader{r: e.port, deadline: deadline})
+		if err != nil {
+			return nil, err
+		}
+
+		// The widget may send received DMX and other messages meanwhile.
+		if replyLabel == label {
+			return reply, nil
+		}
+	}
+}
+
+// writeMessage writes a message of the widget, made of its label and data
+// between a start and an end delimiter.
+func writeMessage(w io.Writer, label byte, data []byte) error {
+	if len(data) > enttecMaxData {
+		return fmt.Errorf("%w: %d bytes of data", ErrBadMessage, len(data))
+	}
+
+	b := make([]byte, 0, 5+len(data))
+	b = append(b, enttecStart, label)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(data)))
+	b = append(b, data...)
+	b = append(b, enttecEnd)
+
+	_, err := w.Write(b)
+	return err
+}
+
+// readMessage reads the next message of the widget, skipping any byte before
+// its start delimiter.
+func readMessage(r io.Reader) (byte, []byte, error) {
+	b := make([]byte, 1)
+	for b[0] != enttecStart {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := int(binary.LittleEndian.Uint16(header[1:]))
+	if length > enttecMaxData {
+		return 0, nil, fmt.Errorf("%w: %d bytes of data", ErrBadMessage, length)
+	}
+
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+
+	if data[length] != enttecEnd {
+		return 0, nil, fmt.Errorf("%w: missing end delimiter", ErrBadMessage)
+	}
+
+	return header[0], data[:length], nil
+}
+
+// timeoutReader retries the reads returning nothing, as those of the ftdi
+// devices do while no data is available, until a deadline. The deadline is
+// only checked between reads, which must therefore not block.
+type timeoutReader struct {
+	r        io.Reader
+	deadline time.Time
+}
+
+func (t *timeoutReader) Read(b []byte) (int, error) {
+	for {
+		n, err := t.r.Read(b)
+		if n > 0 || err != nil {
+			return n, err
+		}
+
+		if time.Now().After(t.deadline) {
+			return 0, ErrTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
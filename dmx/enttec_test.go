@@ -0,0 +1,197 @@
+package dmx
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePort is a serial port replying with the given bytes, and returning
+// nothing once they are read, as the ftdi devices do.
+type fakePort struct {
+	replies bytes.Buffer
+	written bytes.Buffer
+	closed  bool
+}
+
+func (p *fakePort) Read(b []byte) (int, error) {
+	if p.replies.Len() == 0 {
+		return 0, nil
+	}
+
+	// The replies come a byte at a time, as they may from the widget.
+	return p.replies.Read(b[:1])
+}
+
+func (p *fakePort) Write(b []byte) (int, error) {
+	return p.written.Write(b)
+}
+
+func (p *fakePort) Close() error {
+	p.closed = true
+	return nil
+}
+
+func (p *fakePort) reply(t *testing.T, label byte, data []byte) {
+	t.Helper()
+
+	if err := writeMessage(&p.replies, label, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnttecMessage(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeMessage(&b, labelSendDMX, []byte{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{enttecStart, labelSendDMX, 3, 0, 0, 1, 2, enttecEnd}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Fatalf("got message % x, want % x", b.Bytes(), want)
+	}
+
+	// Bytes before the start delimiter are skipped.
+	r := bytes.NewReader(append([]byte{0x00, enttecEnd}, want...))
+	label, data, err := readMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != labelSendDMX || !bytes.Equal(data, []byte{0, 1, 2}) {
+		t.Fatalf("got label %d with data % x, want label %d with data 00 01 02", label, data, labelSendDMX)
+	}
+
+	_, _, err = readMessage(bytes.NewReader([]byte{enttecStart, labelSendDMX, 1, 0, 0, 0}))
+	if !errors.Is(err, ErrBadMessage) {
+		t.Fatalf("got error %v for a message without end delimiter, want %v", err, ErrBadMessage)
+	}
+
+	_, _, err = readMessage(bytes.NewReader([]byte{enttecStart, labelSendDMX, 0xff, 0xff}))
+	if !errors.Is(err, ErrBadMessage) {
+		t.Fatalf("got error %v for a message too large, want %v", err, ErrBadMessage)
+	}
+
+	if err := writeMessage(&b, labelSendDMX, make([]byte, enttecMaxData+1)); !errors.Is(err, ErrBadMessage) {
+		t.Fatalf("got error %v for data too large, want %v", err, ErrBadMessage)
+	}
+}
+
+func TestEnttecSerialNumber(t *testing.T) {
+	port := &fakePort{}
+	e := NewEnttecPro(port)
+
+	// Received DMX and other messages sent before the reply are skipped.
+	port.reply(t, 5, []byte{0, 0, 1, 2})
+	port.reply(t, labelGetParams, []byte{0, 0, 0, 0, 0})
+	port.reply(t, labelSerialNumber, []byte{0x78, 0x56, 0x34, 0x12})
+
+	serial, err := e.SerialNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != 12345678 {
+		t.Fatalf("got serial number %d, want 12345678", serial)
+	}
+
+	want := []byte{enttecStart, labelSerialNumber, 0, 0, enttecEnd}
+	if !bytes.Equal(port.written.Bytes(), want) {
+		t.Fatalf("got request % x, want % x", port.written.Bytes(), want)
+	}
+}
+
+func TestEnttecParams(t *testing.T) {
+	port := &fakePort{}
+	e := NewEnttecPro(port)
+
+	port.reply(t, labelGetParams, []byte{0x04, 0x01, 17, 2, 40})
+
+	params, err := e.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := WidgetParams{
+		Firmware:  0x0104,
+		BreakTime: 17 * enttecUnit,
+		MABTime:   2 * enttecUnit,
+		Rate:      40,
+	}
+	if params != want {
+		t.Fatalf("got parameters %+v, want %+v", params, want)
+	}
+
+	request := []byte{enttecStart, labelGetParams, 2, 0, 0, 0, enttecEnd}
+	if !bytes.Equal(port.written.Bytes(), request) {
+		t.Fatalf("got request % x, want % x", port.written.Bytes(), request)
+	}
+
+	// Replies too short for the parameters are rejected.
+	port.reply(t, labelGetParams, []byte{0x04, 0x01})
+	if _, err := e.Params(); !errors.Is(err, ErrBadMessage) {
+		t.Fatalf("got error %v, want %v", err, ErrBadMessage)
+	}
+}
+
+func TestEnttecSetParams(t *testing.T) {
+	port := &fakePort{}
+	e := NewEnttecPro(port)
+
+	// The timings are rounded up to the unit of the widget.
+	err := e.SetParams(WidgetParams{
+		BreakTime: DefaultBreakTime,
+		MABTime:   DefaultMABTime,
+		Rate:      40,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{enttecStart, labelSetParams, 5, 0, 0, 0, 17, 2, 40, enttecEnd}
+	if !bytes.Equal(port.written.Bytes(), want) {
+		t.Fatalf("got request % x, want % x", port.written.Bytes(), want)
+	}
+
+	for _, params := range []WidgetParams{
+		{BreakTime: 80 * time.Microsecond, MABTime: DefaultMABTime},
+		{BreakTime: DefaultBreakTime, MABTime: 2 * time.Millisecond},
+		{BreakTime: DefaultBreakTime, MABTime: DefaultMABTime, Rate: 41},
+	} {
+		if err := e.SetParams(params); !errors.Is(err, ErrBadParameters) {
+			t.Fatalf("got error %v for %+v, want %v", err, params, ErrBadParameters)
+		}
+	}
+}
+
+func TestEnttecTimeout(t *testing.T) {
+	e := NewEnttecPro(&fakePort{})
+
+	if _, err := e.SerialNumber(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got error %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestEnttecRender(t *testing.T) {
+	port := &fakePort{}
+	e := NewEnttecPro(port)
+
+	if err := e.SetChannels(0, 2, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Render(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The universe is sent after its null start code.
+	b := port.written.Bytes()
+	if len(b) != 5+1+MaxChannel {
+		t.Fatalf("got a message of %d bytes, want %d", len(b), 5+1+MaxChannel)
+	}
+	if !bytes.Equal(b[:8], []byte{enttecStart, labelSendDMX, 0x01, 0x02, 0, 0, 1, 2}) {
+		t.Fatalf("got message starting with % x", b[:8])
+	}
+
+	if err := e.Close(); err != nil || !port.closed {
+		t.Fatalf("got error %v closing the widget, closed %t", err, port.closed)
+	}
+}
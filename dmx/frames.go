@@ -0,0 +1,69 @@
+package dmx
+
+import (
+	"sync"
+)
+
+// frames holds the universes of the outputs refreshing them on their own:
+// the channels set since the last render are pending, and those rendered are
+// sent on every refresh.
+type frames struct {
+	pending    [][]byte
+	pendingMu  sync.Mutex
+	rendered   [][]byte
+	renderedMu sync.RWMutex
+}
+
+func newFrames(universes int) *frames {
+	f := &frames{
+		pending:  make([][]byte, universes),
+		rendered: make([][]byte, universes),
+	}
+	for idx := range f.pending {
+		f.pending[idx] = make([]byte, MaxChannel)
+		f.rendered[idx] = make([]byte, MaxChannel)
+	}
+
+	return f
+}
+
+func (f *frames) universes() int {
+	return len(f.pending)
+}
+
+func (f *frames) set(universe int, start int, values []byte) error {
+	if err := checkRange(f.universes(), universe, start, len(values)); err != nil {
+		return err
+	}
+
+	f.pendingMu.Lock()
+	defer f.pendingMu.Unlock()
+
+	copy(f.pending[universe][start-1:], values)
+
+	return nil
+}
+
+// render makes the channels set so far those sent on the next refreshes.
+func (f *frames) render() {
+	f.pendingMu.Lock()
+	defer f.pendingMu.Unlock()
+
+	f.renderedMu.Lock()
+	defer f.renderedMu.Unlock()
+
+	for idx := range f.pending {
+		copy(f.rendered[idx], f.pending[idx])
+	}
+}
+
+// each calls the given function with the rendered channels of every universe,
+// which must not be kept after it returns.
+func (f *frames) each(fn func(universe int, channels []byte)) {
+	f.renderedMu.RLock()
+	defer f.renderedMu.RUnlock()
+
+	for idx, channels := range f.rendered {
+		fn(idx, channels)
+	}
+}
@@ -0,0 +1,219 @@
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ziutek/ftdi"
+)
+
+const (
+	vendorID  = 0x0403
+	productID = 0x6001
+	baudRate  = 250000
+
+	// MinBreakTime and MinMABTime are the shortest break and mark after break
+	// sent by a transmitter, and DefaultBreakTime and DefaultMABTime those
+	// commonly used to leave a margin to the receivers.
+	MinBreakTime     = time.Duration(time.Microsecond * 92)
+	MinMABTime       = time.Duration(time.Microsecond * 12)
+	DefaultBreakTime = time.Duration(time.Microsecond * 176)
+	DefaultMABTime   = time.Duration(time.Microsecond * 16)
+
+	// NullStartCode is the start code of the packets of dimmer data.
+	NullStartCode = 0x00
+)
+
+var (
+	ErrBadStartCode = errors.New("invalid alternate start code")
+)
+
+// FTDIConfig configures the timing of the packets of an FTDI output.
+type FTDIConfig struct {
+	// BreakTime and MABTime are the durations of the break and of the mark
+	// after break, raised to their minimum.
+	BreakTime time.Duration
+	MABTime   time.Duration
+	// Rate is the delay between two packets of dimmer data.
+	Rate time.Duration
+}
+
+// FTDI is an Open DMX output, driving a single universe through an FTDI
+// serial adapter. The adapter has no timing of its own, so the universe is
+// sent again and again by a goroutine, the break and the mark after break
+// lasting at least the configured durations.
+type FTDI struct {
+	dev *ftdi.Device
+	cfg FTDIConfig
+
+	frames *frames
+
+	// alternates are the packets of alternate start codes waiting to be sent
+	// between two packets of dimmer data.
+	alternates   [][]byte
+	alternatesMu sync.Mutex
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeErr  error
+	closeOnce sync.Once
+}
+
+func OpenFTDI(cfg FTDIConfig) (*FTDI, error) {
+	cfg.BreakTime = max(cfg.BreakTime, MinBreakTime)
+	cfg.MABTime = max(cfg.MABTime, MinMABTime)
+	if cfg.Rate <= 0 {
+		cfg.Rate = DefaultRate
+	}
+
+	dev, err := ftdi.OpenFirst(vendorID, productID, ftdi.ChannelAny)
+	if err != nil {
+		return nil, fmt.Errorf("could not open ftdi device: %w", err)
+	}
+
+	if err := setupFTDI(dev); err != nil {
+		dev.Close()
+		return nil, err
+	}
+
+	d := &FTDI{
+		dev:     dev,
+		cfg:     cfg,
+		frames:  newFrames(1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go d.refresh()
+
+	return d, nil
+}
+
+func setupFTDI(dev *ftdi.Device) error {
+	if err := dev.Reset(); err != nil {
+		return fmt.Errorf("could not reset ftdi device: %w", err)
+	}
+
+	if err := dev.SetBaudrate(baudRate); err != nil {
+		return fmt.Errorf("could not set baud rate for ftdi device: %w", err)
+	}
+
+	if err := dev.SetLineProperties(ftdi.DataBits8, ftdi.StopBits2, ftdi.ParityNone); err != nil {
+		return fmt.Errorf("could not set line properties for ftdi device: %w", err)
+	}
+
+	if err := dev.SetFlowControl(ftdi.FlowCtrlDisable); err != nil {
+		return fmt.Errorf("could not set flow control for ftdi device: %w", err)
+	}
+
+	return nil
+}
+
+// Close stops the refresh of the universe before closing the device, once.
+func (d *FTDI) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		<-d.stopped
+
+		d.closeErr = d.dev.Close()
+	})
+
+	return d.closeErr
+}
+
+func (d *FTDI) SetChannel(universe int, id int, value byte) error {
+	return d.SetChannels(universe, id, []byte{value})
+}
+
+func (d *FTDI) SetChannels(universe int, start int, values []byte) error {
+	return d.frames.set(universe, start, values)
+}
+
+func (d *FTDI) Universes() int {
+	return 1
+}
+
+// Render makes the channels set so far those sent on the next refreshes.
+func (d *FTDI) Render() error {
+	d.frames.render()
+
+	return nil
+}
+
+// SendAlternate sends a packet of an alternate start code once, between two
+// packets of dimmer data. The slots of the packet follow the start code.
+func (d *FTDI) SendAlternate(startCode byte, slots []byte) error {
+	if startCode == NullStartCode {
+		return fmt.Errorf("%w: %#x is the null start code", ErrBadStartCode, startCode)
+	}
+
+	if len(slots) > MaxChannel {
+		return fmt.Errorf("%w: %d slots, want at most %d", ErrBadChannel, len(slots), MaxChannel)
+	}
+
+	d.alternatesMu.Lock()
+	defer d.alternatesMu.Unlock()
+
+	d.alternates = append(d.alternates, append([]byte{startCode}, slots...))
+
+	return nil
+}
+
+func (d *FTDI) refresh() {
+	defer close(d.stopped)
+
+	t := time.NewTicker(d.cfg.Rate)
+	defer t.Stop()
+
+	packet := make([]byte, 1+MaxChannel)
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-t.C:
+		}
+
+		d.alternatesMu.Lock()
+		alternates := d.alternates
+		d.alternates = nil
+		d.alternatesMu.Unlock()
+
+		for _, alternate := range alternates {
+			if err := d.send(alternate); err != nil {
+				fmt.Printf("could not send alternate dmx packet: %s\n", err)
+			}
+		}
+
+		packet[0] = NullStartCode
+		d.frames.each(func(_ int, channels []byte) {
+			copy(packet[1:], channels)
+		})
+
+		if err := d.send(packet); err != nil {
+			fmt.Printf("could not send dmx packet: %s\n", err)
+		}
+	}
+}
+
+// send writes a packet, its start code first, after a break and a mark after
+// break. Sleeping only guarantees their minimum durations, the round-trips to
+// the device lengthening them.
+func (d *FTDI) send(packet []byte) error {
+	if err := d.dev.SetLineProperties2(ftdi.DataBits8, ftdi.StopBits2, ftdi.ParityNone, ftdi.BreakOn); err != nil {
+		return fmt.Errorf("could not enable break mode for ftdi device: %w", err)
+	}
+	time.Sleep(d.cfg.BreakTime)
+
+	if err := d.dev.SetLineProperties2(ftdi.DataBits8, ftdi.StopBits2, ftdi.ParityNone, ftdi.BreakOff); err != nil {
+		return fmt.Errorf("could not disable break mode for ftdi device: %w", err)
+	}
+	time.Sleep(d.cfg.MABTime)
+
+	if _, err := d.dev.Write(packet); err != nil {
+		return fmt.Errorf("could not write packet to ftdi device: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"essaim.dev/essaim/transport"
+)
+
+const (
+	// MaxChannel is the last DMX channel of the universe.
+	MaxChannel = 512
+
+	// DefaultRate is the delay between two refreshes of the universes by the
+	// outputs refreshing them on their own, the fastest rate at which a full
+	// universe is sent on a DMX line.
+	DefaultRate = time.Duration(time.Millisecond * 25)
+)
+
+const (
+	OutputFTDI    = "ftdi"
+	OutputVirtual = "virtual"
+	OutputArtNet  = "artnet"
+	OutputSACN    = "sacn"
+	OutputEnttec  = "enttec"
+)
+
+var (
+	ErrBadUniverse = errors.New("universe is out of the output")
+	ErrBadChannel  = errors.New("channel is out of the universe")
+)
+
+// Output sends universes of DMX channels to the fixtures.
+type Output interface {
+	// SetChannel sets the value of a channel, numbered from 1, of a universe,
+	// numbered from 0. It is sent on the next render.
+	SetChannel(universe int, id int, value byte) error
+	// SetChannels sets the values of consecutive channels of a universe,
+	// starting at the given channel.
+	SetChannels(universe int, start int, values []byte) error
+	// Render sends the channels of every universe, or makes them those sent
+	// on the next refreshes by the outputs refreshing them on their own.
+	Render() error
+	Close() error
+	// Universes returns the number of universes of the output.
+	Universes() int
+}
+
+type OutputConfig struct {
+	Kind string
+
+	// Universes is the number of universes of the outputs which are not bound
+	// to a single one.
+	Universes int
+
+	// Addr is the address to which the network universes are sent, a node or
+	// a broadcast address, or the zero value to send Art-Net universes to the
+	// nodes outputting them and sACN universes to their multicast groups.
+	Addr netip.AddrPort
+	// UDPConfig configures the sACN multicast sockets.
+	UDPConfig transport.UDPConfig
+	// Net, SubNet and Universe make the Art-Net port address of the first
+	// universe, the next ones following it.
+	Net      int
+	SubNet   int
+	Universe int
+	// SACNUniverse is the first sACN universe, from 1, the next ones following
+	// it.
+	SACNUniverse int
+	CID          CID
+	SourceName   string
+	Priority     int
+	// Rate is the delay between two refreshes of the universes of the ftdi
+	// and network outputs.
+	Rate time.Duration
+	// BreakTime and MABTime are the durations of the break and of the mark
+	// after break sent by the ftdi and enttec outputs.
+	BreakTime time.Duration
+	MABTime   time.Duration
+}
+
+func OpenOutput(cfg OutputConfig) (Output, error) {
+	switch cfg.Kind {
+	case OutputFTDI:
+		return OpenFTDI(FTDIConfig{
+			BreakTime: cfg.BreakTime,
+			MABTime:   cfg.MABTime,
+			Rate:      cfg.Rate,
+		})
+	case OutputEnttec:
+		return openEnttecPro(cfg)
+	case OutputVirtual:
+		return NewVirtual(max(cfg.Universes, 1)), nil
+	case OutputArtNet:
+		return OpenArtNet(cfg.Addr, cfg.Net, cfg.SubNet, cfg.Universe, max(cfg.Universes, 1), cfg.Rate)
+	case OutputSACN:
+		return OpenSACN(SACNConfig{
+			Addr:       cfg.Addr,
+			UDPConfig:  cfg.UDPConfig,
+			Universe:   cfg.SACNUniverse,
+			Universes:  max(cfg.Universes, 1),
+			CID:        cfg.CID,
+			SourceName: cfg.SourceName,
+			Priority:   cfg.Priority,
+			Rate:       cfg.Rate,
+		})
+	default:
+		return nil, fmt.Errorf("unknown dmx output: %q", cfg.Kind)
+	}
+}
+
+// openEnttecPro opens the first widget connected, and sets the timings of its
+// packets, keeping its rate.
+func openEnttecPro(cfg OutputConfig) (*EnttecPro, error) {
+	e, err := OpenEnttecPro()
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := e.Params()
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	params.BreakTime = max(cfg.BreakTime, MinBreakTime)
+	params.MABTime = max(cfg.MABTime, MinMABTime)
+	if err := e.SetParams(params); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// checkRange makes sure the given channels fit in a universe of an output.
+func checkRange(universes int, universe int, start int, count int) error {
+	if universe < 0 || universe >= universes {
+		return fmt.Errorf("%w: %d, want 0 to %d", ErrBadUniverse, universe, universes-1)
+	}
+
+	if start < 1 || start+count-1 > MaxChannel {
+		return fmt.Errorf("%w: %d to %d, want 1 to %d", ErrBadChannel, start, start+count-1, MaxChannel)
+	}
+
+	return nil
+}
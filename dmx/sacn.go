@@ -0,0 +1,269 @@
+package dmx
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/transport"
+)
+
+const (
+	SACNPort = 5568
+
+	// DefaultSACNPriority is the priority of the sources which do not ask for
+	// any, below the one of a console taking over.
+	DefaultSACNPriority = 100
+	MaxSACNPriority     = 200
+	MaxSACNUniverse     = 63999
+
+	sacnRootVector    = 0x00000004
+	sacnFramingVector = 0x00000002
+	sacnDMPVector     = 0x02
+
+	// sacnTerminated is the option telling the receivers that the source
+	// stopped sending the universe, and sacnTerminations the number of packets
+	// carrying it.
+	sacnTerminated   = 0x40
+	sacnTerminations = 3
+)
+
+var sacnID = []byte("ASC-E1.17\x00\x00\x00")
+
+var (
+	ErrBadSACNUniverse = errors.New("sacn universe is out of range")
+	ErrBadPriority     = errors.New("sacn priority is out of range")
+	ErrBadCID          = errors.New("invalid sacn component identifier")
+)
+
+// CID identifies a source of sACN universes, and should stay the same for a
+// given source across restarts.
+type CID [16]byte
+
+// NewCID returns a random component identifier.
+func NewCID() CID {
+	cid := CID{}
+	rand.Read(cid[:])
+
+	// Random UUIDs are of version 4 and of the RFC 4122 variant.
+	cid[6] = cid[6]&0x0f | 0x40
+	cid[8] = cid[8]&0x3f | 0x80
+
+	return cid
+}
+
+// ParseCID parses a component identifier written as a UUID.
+func ParseCID(s string) (CID, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != len(CID{}) {
+		return CID{}, fmt.Errorf("%w: %q", ErrBadCID, s)
+	}
+
+	return CID(b), nil
+}
+
+func (c CID) String() string {
+	h := hex.EncodeToString(c[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// SACNConfig configures a source of sACN universes.
+type SACNConfig struct {
+	// Addr is the address to which every universe is sent, or the zero value
+	// to send each one to its multicast group.
+	Addr netip.AddrPort
+	// UDPConfig configures the multicast sockets.
+	UDPConfig transport.UDPConfig
+
+	// Universe is the first universe, from 1, the next ones following it.
+	Universe  int
+	Universes int
+
+	// CID identifies the source, a random one being used if zero.
+	CID        CID
+	SourceName string
+	// Priority is the priority of the universes, from 0 to 200, the receivers
+	// using those of highest priority when several sources send them.
+	Priority int
+
+	Rate time.Duration
+}
+
+// SACN is an output sending its universes as E1.31 streams, at its own
+// refresh rate. The receivers are told when the streams stop on close, so
+// that they fall back to other sources, such as the house console, at once.
+type SACN struct {
+	conns []*net.UDPConn
+
+	cfg       SACNConfig
+	frames    *frames
+	sequences []byte
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// SACNGroup returns the multicast group of a universe.
+func SACNGroup(universe int) netip.AddrPort {
+	return netip.AddrPortFrom(netip.AddrFrom4([4]byte{239, 255, byte(universe >> 8), byte(universe)}), SACNPort)
+}
+
+func OpenSACN(cfg SACNConfig) (*SACN, error) {
+	if cfg.Universe < 1 || cfg.Universe+cfg.Universes-1 > MaxSACNUniverse {
+		return nil, fmt.Errorf("%w: %d universes from %d, want 1 to %d", ErrBadSACNUniverse, cfg.Universes, cfg.Universe, MaxSACNUniverse)
+	}
+
+	if cfg.Priority < 0 || cfg.Priority > MaxSACNPriority {
+		return nil, fmt.Errorf("%w: %d, want 0 to %d", ErrBadPriority, cfg.Priority, MaxSACNPriority)
+	}
+
+	if cfg.CID == (CID{}) {
+		cfg.CID = NewCID()
+	}
+
+	if cfg.Rate <= 0 {
+		cfg.Rate = DefaultRate
+	}
+
+	s := &SACN{
+		cfg:       cfg,
+		frames:    newFrames(cfg.Universes),
+		sequences: make([]byte, cfg.Universes),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	for idx := range cfg.Universes {
+		addr := cfg.Addr
+		if !addr.IsValid() {
+			addr = SACNGroup(cfg.Universe + idx)
+		}
+
+		conn, err := transport.DialUDP(addr, cfg.UDPConfig)
+		if err != nil {
+			s.closeConns()
+			return nil, err
+		}
+		s.conns = append(s.conns, conn)
+	}
+
+	go s.refresh()
+
+	return s, nil
+}
+
+func (s *SACN) SetChannel(universe int, id int, value byte) error {
+	return s.SetChannels(universe, id, []byte{value})
+}
+
+func (s *SACN) SetChannels(universe int, start int, values []byte) error {
+	return s.frames.set(universe, start, values)
+}
+
+// Render makes the channels set so far those sent on the next refreshes.
+func (s *SACN) Render() error {
+	s.frames.render()
+
+	return nil
+}
+
+// Close terminates the streams of every universe before closing the output.
+func (s *SACN) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	<-s.stopped
+
+	var errs []error
+	for range sacnTerminations {
+		errs = append(errs, s.send(sacnTerminated))
+	}
+	errs = append(errs, s.closeConns())
+
+	return errors.Join(errs...)
+}
+
+func (s *SACN) closeConns() error {
+	var errs []error
+	for _, conn := range s.conns {
+		errs = append(errs, conn.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *SACN) Universes() int {
+	return s.frames.universes()
+}
+
+func (s *SACN) refresh() {
+	defer close(s.stopped)
+
+	t := time.NewTicker(s.cfg.Rate)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+		}
+
+		if err := s.send(0); err != nil {
+			fmt.Printf("could not send sacn universes: %s\n", err)
+		}
+	}
+}
+
+func (s *SACN) send(options byte) error {
+	var errs []error
+	s.frames.each(func(universe int, channels []byte) {
+		s.sequences[universe]++
+
+		packet := s.packet(s.cfg.Universe+universe, s.sequences[universe], options, channels)
+		if _, err := s.conns[universe].Write(packet); err != nil {
+			errs = append(errs, err)
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// packet returns the E1.31 data packet of a universe, made of the root,
+// framing and DMP layers, each starting with its flags and length.
+func (s *SACN) packet(universe int, sequence byte, options byte, channels []byte) []byte {
+	b := make([]byte, 126+len(channels))
+
+	binary.BigEndian.PutUint16(b[0:], 0x0010)
+	copy(b[4:16], sacnID)
+	binary.BigEndian.PutUint16(b[16:], 0x7000|uint16(len(b)-16))
+	binary.BigEndian.PutUint32(b[18:], sacnRootVector)
+	copy(b[22:38], s.cfg.CID[:])
+
+	binary.BigEndian.PutUint16(b[38:], 0x7000|uint16(len(b)-38))
+	binary.BigEndian.PutUint32(b[40:], sacnFramingVector)
+	// The source name is null-terminated.
+	copy(b[44:107], s.cfg.SourceName)
+	b[108] = byte(s.cfg.Priority)
+	b[111] = sequence
+	b[112] = options
+	binary.BigEndian.PutUint16(b[113:], uint16(universe))
+
+	binary.BigEndian.PutUint16(b[115:], 0x7000|uint16(len(b)-115))
+	b[117] = sacnDMPVector
+	b[118] = 0xa1
+	binary.BigEndian.PutUint16(b[121:], 0x0001)
+	binary.BigEndian.PutUint16(b[123:], uint16(1+len(channels)))
+	// The start code at b[125] is the null one of dimmer data.
+	copy(b[126:], channels)
+
+	return b
+}
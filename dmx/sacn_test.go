@@ -0,0 +1,232 @@
+package dmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+var testCID = CID{0: 0xe5, 6: 0x40, 8: 0x80, 15: 0x01}
+
+// newTestSACN returns an output sending its universes to a local listener,
+// without refreshing them on its own.
+func newTestSACN(t *testing.T, universe int, universes int) (*SACN, *net.UDPConn) {
+	t.Helper()
+
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s, err := OpenSACN(SACNConfig{
+		Addr:       listener.LocalAddr().(*net.UDPAddr).AddrPort(),
+		Universe:   universe,
+		Universes:  universes,
+		CID:        testCID,
+		SourceName: "essaim",
+		Priority:   150,
+		Rate:       time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, listener
+}
+
+// expectLayer checks the flags and length of the layer starting at the given
+// offset, which spans the rest of the packet, and its vector.
+func expectLayer(t *testing.T, packet []byte, name string, offset int, vector uint32, vectorSize int) {
+	t.Helper()
+
+	flagsLength := binary.BigEndian.Uint16(packet[offset:])
+	if flags, length := flagsLength>>12, int(flagsLength&0x0fff); flags != 0x7 || length != len(packet)-offset {
+		t.Fatalf("got %s layer flags %#x and length %d, want 0x7 and %d", name, flags, length, len(packet)-offset)
+	}
+
+	var got uint32
+	for _, b := range packet[offset+2 : offset+2+vectorSize] {
+		got = got<<8 | uint32(b)
+	}
+	if got != vector {
+		t.Fatalf("got %s layer vector %#x, want %#x", name, got, vector)
+	}
+}
+
+func expectSACNPacket(t *testing.T, packet []byte, universe int, sequence byte, options byte) {
+	t.Helper()
+
+	if len(packet) != 126+MaxChannel {
+		t.Fatalf("got a packet of %d bytes, want %d", len(packet), 126+MaxChannel)
+	}
+
+	// Root layer.
+	if preamble := binary.BigEndian.Uint16(packet[0:]); preamble != 0x0010 {
+		t.Fatalf("got preamble size %#x, want 0x0010", preamble)
+	}
+	if postamble := binary.BigEndian.Uint16(packet[2:]); postamble != 0 {
+		t.Fatalf("got postamble size %#x, want 0", postamble)
+	}
+	if !bytes.Equal(packet[4:16], sacnID) {
+		t.Fatalf("got id %q, want %q", packet[4:16], sacnID)
+	}
+	expectLayer(t, packet, "root", 16, sacnRootVector, 4)
+	if cid := CID(packet[22:38]); cid != testCID {
+		t.Fatalf("got cid %s, want %s", cid, testCID)
+	}
+
+	// Framing layer.
+	expectLayer(t, packet, "framing", 38, sacnFramingVector, 4)
+	if name := packet[44:108]; !bytes.Equal(name, append([]byte("essaim"), make([]byte, 58)...)) {
+		t.Fatalf("got source name %q, want a null-terminated %q", name, "essaim")
+	}
+	if packet[108] != 150 {
+		t.Fatalf("got priority %d, want 150", packet[108])
+	}
+	if packet[111] != sequence {
+		t.Fatalf("got sequence %d, want %d", packet[111], sequence)
+	}
+	if packet[112] != options {
+		t.Fatalf("got options %#x, want %#x", packet[112], options)
+	}
+	if got := binary.BigEndian.Uint16(packet[113:]); int(got) != universe {
+		t.Fatalf("got universe %d, want %d", got, universe)
+	}
+
+	// DMP layer.
+	expectLayer(t, packet, "dmp", 115, sacnDMPVector, 1)
+	if packet[118] != 0xa1 {
+		t.Fatalf("got address and data type %#x, want 0xa1", packet[118])
+	}
+	if first := binary.BigEndian.Uint16(packet[119:]); first != 0 {
+		t.Fatalf("got first property address %d, want 0", first)
+	}
+	if increment := binary.BigEndian.Uint16(packet[121:]); increment != 1 {
+		t.Fatalf("got address increment %d, want 1", increment)
+	}
+	if count := binary.BigEndian.Uint16(packet[123:]); count != 1+MaxChannel {
+		t.Fatalf("got property value count %d, want %d", count, 1+MaxChannel)
+	}
+	if packet[125] != 0 {
+		t.Fatalf("got start code %#x, want 0", packet[125])
+	}
+}
+
+func TestSACNSend(t *testing.T) {
+	s, listener := newTestSACN(t, 7, 2)
+	defer s.Close()
+
+	if err := s.SetChannels(0, 1, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChannel(1, MaxChannel, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// Channels are only sent once rendered.
+	if err := s.send(0); err != nil {
+		t.Fatal(err)
+	}
+	for universe := range 2 {
+		packet := readPacket(t, listener)
+		expectSACNPacket(t, packet, 7+universe, 1, 0)
+		if !bytes.Equal(packet[126:], make([]byte, MaxChannel)) {
+			t.Fatalf("got channels set before rendering in universe %d", 7+universe)
+		}
+	}
+
+	s.Render()
+	if err := s.send(0); err != nil {
+		t.Fatal(err)
+	}
+
+	packet := readPacket(t, listener)
+	expectSACNPacket(t, packet, 7, 2, 0)
+	if !bytes.Equal(packet[126:129], []byte{1, 2, 3}) {
+		t.Fatalf("got channels % x, want 01 02 03", packet[126:129])
+	}
+
+	packet = readPacket(t, listener)
+	expectSACNPacket(t, packet, 8, 2, 0)
+	if packet[len(packet)-1] != 4 {
+		t.Fatalf("got last channel %d, want 4", packet[len(packet)-1])
+	}
+}
+
+func TestSACNSequence(t *testing.T) {
+	s, listener := newTestSACN(t, 1, 1)
+	defer s.Close()
+
+	// The sequence of every universe wraps around, 0 included.
+	s.sequences[0] = 254
+	for _, sequence := range []byte{255, 0, 1} {
+		if err := s.send(0); err != nil {
+			t.Fatal(err)
+		}
+		expectSACNPacket(t, readPacket(t, listener), 1, sequence, 0)
+	}
+}
+
+func TestSACNClose(t *testing.T) {
+	s, listener := newTestSACN(t, 1, 2)
+
+	if err := s.send(0); err != nil {
+		t.Fatal(err)
+	}
+	for universe := range 2 {
+		expectSACNPacket(t, readPacket(t, listener), 1+universe, 1, 0)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The streams are terminated, the sequences going on.
+	for idx := range sacnTerminations {
+		for universe := range 2 {
+			expectSACNPacket(t, readPacket(t, listener), 1+universe, byte(2+idx), sacnTerminated)
+		}
+	}
+
+	listener.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, err := listener.Read(make([]byte, 1500)); err == nil {
+		t.Fatalf("got a packet of %d bytes after the terminations", n)
+	}
+}
+
+func TestOpenSACNInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cfg  SACNConfig
+		err  error
+	}{
+		{"universe zero", SACNConfig{Universe: 0, Universes: 1}, ErrBadSACNUniverse},
+		{"past the last universe", SACNConfig{Universe: MaxSACNUniverse, Universes: 2}, ErrBadSACNUniverse},
+		{"negative priority", SACNConfig{Universe: 1, Universes: 1, Priority: -1}, ErrBadPriority},
+		{"priority too high", SACNConfig{Universe: 1, Universes: 1, Priority: MaxSACNPriority + 1}, ErrBadPriority},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := OpenSACN(test.cfg); !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseCID(t *testing.T) {
+	cid, err := ParseCID(testCID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cid != testCID {
+		t.Fatalf("got cid %s, want %s", cid, testCID)
+	}
+
+	if _, err := ParseCID("not-a-uuid"); !errors.Is(err, ErrBadCID) {
+		t.Fatalf("got error %v, want %v", err, ErrBadCID)
+	}
+}
@@ -0,0 +1,90 @@
+package dmx
+
+import (
+	"sync"
+)
+
+// Virtual is an output keeping its universes in memory, for rehearsals and
+// tests without any DMX hardware.
+type Virtual struct {
+	universes   [][]byte
+	universesMu sync.RWMutex
+
+	onRender   func(universes [][]byte)
+	onRenderMu sync.RWMutex
+}
+
+func NewVirtual(universes int) *Virtual {
+	v := &Virtual{
+		universes: make([][]byte, universes),
+	}
+	for idx := range v.universes {
+		v.universes[idx] = make([]byte, MaxChannel)
+	}
+
+	return v
+}
+
+// SetOnRenderFunc sets the function called with a copy of the universes every
+// time they are rendered.
+func (v *Virtual) SetOnRenderFunc(f func(universes [][]byte)) {
+	v.onRenderMu.Lock()
+	defer v.onRenderMu.Unlock()
+
+	v.onRender = f
+}
+
+func (v *Virtual) SetChannel(universe int, id int, value byte) error {
+	return v.SetChannels(universe, id, []byte{value})
+}
+
+func (v *Virtual) SetChannels(universe int, start int, values []byte) error {
+	if err := checkRange(len(v.universes), universe, start, len(values)); err != nil {
+		return err
+	}
+
+	v.universesMu.Lock()
+	defer v.universesMu.Unlock()
+
+	copy(v.universes[universe][start-1:], values)
+
+	return nil
+}
+
+// Channels returns a copy of the channels of a universe, the first one at
+// index 0.
+func (v *Virtual) Channels(universe int) []byte {
+	v.universesMu.RLock()
+	defer v.universesMu.RUnlock()
+
+	if universe < 0 || universe >= len(v.universes) {
+		return nil
+	}
+
+	return append([]byte(nil), v.universes[universe]...)
+}
+
+func (v *Virtual) Render() error {
+	v.onRenderMu.RLock()
+	defer v.onRenderMu.RUnlock()
+
+	if v.onRender == nil {
+		return nil
+	}
+
+	universes := make([][]byte, len(v.universes))
+	for idx := range universes {
+		universes[idx] = v.Channels(idx)
+	}
+	v.onRender(universes)
+
+	return nil
+}
+
+func (v *Virtual) Close() error {
+	return nil
+}
+
+func (v *Virtual) Universes() int {
+	return len(v.universes)
+}
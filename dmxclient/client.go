@@ -2,68 +2,186 @@ package dmxclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image/color"
-	"net"
-	"net/netip"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"essaim.dev/essaim/api/essaimbp"
 	"essaim.dev/essaim/clock"
 	"essaim.dev/essaim/dmx"
+	"essaim.dev/essaim/fixture"
 	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/presence"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 )
 
 const (
 	refreshRate = time.Duration(time.Millisecond * 50)
+
+	// stepResolution is the number of steps per beat of the clock followed by
+	// the client, from which the step of every fixture is derived: as many as
+	// a MIDI clock, enough for thirty-second notes, triplets and swing.
+	stepResolution clock.Rate = 24
 )
 
+// sixteenths is the timing of the steps announced for the channels.
+var sixteenths = clock.Timing{Rate: clock.RateSixteenth}
+
 type Client struct {
 	clock clock.Clock
-	conn  *net.UDPConn
+	conn  transport.Conn
 
-	pattern   *pattern.ColorPattern
+	channels  []*channel
 	patternMu sync.RWMutex
+	receiver  *protocol.Receiver
 
-	currentStep atomic.Int32
+	fixtures []patched
+	// values holds the channels of a fixture while it is rendered.
+	values []byte
 
-	dmxDevice *dmx.Device
+	// currentBeat holds the bits of the beat of the current step.
+	currentBeat atomic.Uint64
+
+	output dmx.Output
+}
 
+// channel is the state of an essaim channel played by some fixtures: its
+// pattern and the node announced for it.
+type channel struct {
 	channel uint64
+	pattern *pattern.ColorPattern
+
+	announcer *presence.Announcer
+	// lastStats are the reception stats at the previous announce.
+	lastStats protocol.Stats
 }
 
-func New(clock clock.Clock, stepCount int, iface *net.Interface, addr netip.AddrPort, channel uint64) (*Client, error) {
-	conn, err := net.ListenMulticastUDP("udp4", iface, net.UDPAddrFromAddrPort(addr))
-	if err != nil {
-		return nil, fmt.Errorf("could not listen on multicast address: %w", err)
+type patched struct {
+	fixture.Fixture
+	profile *fixture.Profile
+	timing  clock.Timing
+	channel *channel
+}
+
+// New returns a client playing the pattern of each channel on the fixtures of
+// the patch, through the given output.
+func New(
+	clock clock.Clock,
+	stepCount int,
+	conn transport.Conn,
+	output dmx.Output,
+	patch *fixture.Patch,
+	auth protocol.Auth,
+) (*Client, error) {
+	if err := patch.Check(dmx.MaxChannel); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	if len(patch.Fixtures) == 0 {
+		return nil, errors.New("invalid patch: no fixture")
 	}
-	conn.SetReadBuffer(512)
 
-	dev, err := dmx.OpenDevice()
-	if err != nil {
-		return nil, fmt.Errorf("could not open dmx device: %w", err)
+	c := &Client{
+		clock:    clock,
+		conn:     conn,
+		receiver: protocol.NewReceiver(auth),
+		output:   output,
+	}
+
+	// Each channel is announced as a node of its own, so that the controller
+	// sees every channel played.
+	channels := make(map[uint64]*channel)
+	for _, ch := range patch.Channels() {
+		state := &channel{
+			channel:   ch,
+			pattern:   pattern.NewColorPattern(stepCount),
+			announcer: presence.NewAnnouncer(conn, essaimbp.NODE_ROLE_DMX, ch, auth),
+		}
+		state.announcer.SetStatusFunc(func() presence.Status {
+			return c.status(state)
+		})
+		c.channels = append(c.channels, state)
+		channels[ch] = state
 	}
 
-	return &Client{
-		clock:     clock,
-		conn:      conn,
-		pattern:   pattern.NewColorPattern(stepCount),
-		dmxDevice: dev,
-		channel:   channel,
-	}, nil
+	for _, f := range patch.Fixtures {
+		profile := patch.Profile(f)
+		timing, err := f.Timing()
+		if err != nil {
+			return nil, fmt.Errorf("could not patch %s: %w", f, err)
+		}
+
+		// The fixtures start blacked out, which also makes sure that they
+		// fit in the output.
+		if err := output.SetChannels(f.Universe, f.Address, make([]byte, profile.Footprint())); err != nil {
+			return nil, fmt.Errorf("could not patch %s: %w", f, err)
+		}
+		c.fixtures = append(c.fixtures, patched{
+			Fixture: f,
+			profile: profile,
+			timing:  timing,
+			channel: channels[f.Channel],
+		})
+		c.values = make([]byte, max(len(c.values), profile.Footprint()))
+	}
+
+	return c, nil
 }
 
 func (c *Client) Close() error {
-	c.dmxDevice.Close()
+	c.output.Close()
 	return c.conn.Close()
 }
 
+func (c *Client) requestState() {
+	// The state holds every channel, a single request is enough.
+	if err := c.channels[0].announcer.RequestState(); err != nil {
+		fmt.Printf("could not request state: %s\n", err)
+	}
+}
+
+func (c *Client) status(ch *channel) presence.Status {
+	stats := c.receiver.Stats()
+	health := presence.ReceptionHealth(ch.lastStats, stats)
+	ch.lastStats = stats
+
+	return presence.Status{
+		Step:   sixteenths.StepAt(c.beat()),
+		Health: health,
+	}
+}
+
+func (c *Client) beat() float64 {
+	return math.Float64frombits(c.currentBeat.Load())
+}
+
+// Stats returns the counters of the pattern updates received, including the
+// lost and dropped ones.
+func (c *Client) Stats() protocol.Stats {
+	return c.receiver.Stats()
+}
+
 func (c *Client) Run(ctx context.Context) error {
+	for _, ch := range c.channels {
+		go ch.announcer.Run(ctx)
+	}
+
+	// Connections which may be reestablished request the state every time
+	// they are, as frames may have been missed in the meantime.
+	if connector, ok := c.conn.(transport.Connector); ok {
+		connector.SetOnConnectFunc(c.requestState)
+	} else {
+		c.requestState()
+	}
+
 	connStopped := make(chan error, 1)
 	go c.consumeConn(connStopped)
 
-	ticks := c.clock.Tick()
+	events := clock.WithRate(c.clock, stepResolution, 0).Events()
 
 	refresh := time.NewTicker(refreshRate)
 
@@ -72,12 +190,14 @@ func (c *Client) Run(ctx context.Context) error {
 		case err := <-connStopped:
 			return fmt.Errorf("error while listening for pattern updates: %w", err)
 
-		case tick := <-ticks:
-			c.currentStep.Store(int32(tick % 16))
+		case event, ok := <-events:
+			if !ok {
+				return clock.ErrClosed
+			}
+			c.currentBeat.Store(math.Float64bits(event.Beat))
 
 		case <-refresh.C:
-			col, _ := c.pattern.ColorAt(int(c.currentStep.Load()))
-			c.render(col)
+			c.render(c.beat())
 
 		case <-ctx.Done():
 			return ctx.Err()
@@ -86,27 +206,69 @@ func (c *Client) Run(ctx context.Context) error {
 }
 
 func (c *Client) consumeConn(stopped chan error) {
-	b := make([]byte, 512)
+	b := make([]byte, protocol.MaxFrameSize)
 
 	for {
-		n, err := c.conn.Read(b)
+		n, _, err := c.conn.ReadFrom(b)
 		if err != nil {
-			stopped <- fmt.Errorf("error while reading from udp: %w", err)
+			stopped <- fmt.Errorf("error while reading frames: %w", err)
 			return
 		}
 
+		if err := c.handleFrame(b[:n]); err != nil {
+			fmt.Printf("discarding packet: %s\n", err)
+		}
+	}
+}
+
+func (c *Client) handleFrame(b []byte) error {
+	frame, err := c.receiver.Receive(b)
+	if errors.Is(err, protocol.ErrUnknownType) || errors.Is(err, protocol.ErrStale) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not decode frame: %w", err)
+	}
+
+	switch frame.Header.Type {
+	case essaimbp.MESSAGE_TYPE_PATTERN:
+		c.patternMu.Lock()
+		defer c.patternMu.Unlock()
+
+		for _, ch := range c.channels {
+			if err := ch.pattern.Decode(frame.Payload, ch.channel); err != nil {
+				return fmt.Errorf("could not decode pattern: %w", err)
+			}
+		}
+
+	case essaimbp.MESSAGE_TYPE_STATE:
 		c.patternMu.Lock()
-		c.pattern.Decode(b[:n], c.channel)
-		c.patternMu.Unlock()
+		defer c.patternMu.Unlock()
+
+		for _, ch := range c.channels {
+			if err := ch.pattern.DecodeState(frame.Payload, ch.channel); err != nil {
+				return fmt.Errorf("could not decode state: %w", err)
+			}
+		}
 	}
+
+	return nil
 }
 
-func (c *Client) render(col color.Color) {
-	rgbaCol, _ := color.RGBAModel.Convert(col).(color.RGBA)
+// render renders the fixtures at the given beat, each playing the step of its
+// pattern at its own timing.
+func (c *Client) render(beat float64) {
+	c.patternMu.RLock()
+	for _, f := range c.fixtures {
+		step := f.timing.StepAt(beat)
+		col, _ := f.channel.pattern.ColorAt(f.channel.pattern.StepAt(step))
+		rgbaCol, _ := color.RGBAModel.Convert(col).(color.RGBA)
 
-	c.dmxDevice.SetChannel(1, rgbaCol.R)
-	c.dmxDevice.SetChannel(2, rgbaCol.G)
-	c.dmxDevice.SetChannel(3, rgbaCol.B)
+		values := c.values[:f.profile.Footprint()]
+		f.profile.Render(rgbaCol, values)
+		c.output.SetChannels(f.Universe, f.Address, values)
+	}
+	c.patternMu.RUnlock()
 
-	c.dmxDevice.Render()
+	c.output.Render()
 }
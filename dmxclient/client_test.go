@@ -0,0 +1,253 @@
+package dmxclient
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/clock"
+	"essaim.dev/essaim/dmx"
+	"essaim.dev/essaim/fixture"
+	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/protocol"
+)
+
+// fakeConn delivers the frames written to its incoming side, and discards
+// those sent by the client.
+type fakeConn struct {
+	incoming chan []byte
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		incoming: make(chan []byte, 16),
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ReadFrom(b []byte) (int, netip.AddrPort, error) {
+	select {
+	case frame := <-c.incoming:
+		return copy(b, frame), netip.AddrPort{}, nil
+	case <-c.done:
+		return 0, netip.AddrPort{}, net.ErrClosed
+	}
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+
+	return nil
+}
+
+// controller writes frames to the incoming side of a fake connection.
+type controller chan []byte
+
+func (c controller) Write(b []byte) (int, error) {
+	c <- append([]byte(nil), b...)
+	return len(b), nil
+}
+
+func sendPattern(t *testing.T, sender *protocol.Sender, ch uint64, colors ...color.RGBA) {
+	t.Helper()
+
+	p := pattern.NewColorPattern(len(colors))
+	for idx, col := range colors {
+		p.SetColorAt(idx, col)
+	}
+
+	if err := sender.Send(essaimbp.MESSAGE_TYPE_PATTERN, p.Encode(ch)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitUniverses waits for a render of the output matching the given channels,
+// each starting at channel 1 of its universe.
+func waitUniverses(t *testing.T, renders <-chan [][]byte, want ...[]byte) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	var last [][]byte
+	for {
+		select {
+		case universes := <-renders:
+			last = universes
+			matches := true
+			for idx, channels := range want {
+				if !bytes.Equal(universes[idx][:len(channels)], channels) {
+					matches = false
+				}
+			}
+			if matches {
+				return
+			}
+
+		case <-timeout:
+			t.Fatalf("got universes starting with % x, want % x", last, want)
+		}
+	}
+}
+
+func TestClientRender(t *testing.T) {
+	clk := clock.NewVirtualClock(120)
+	defer clk.Close()
+
+	conn := newFakeConn()
+	output := dmx.NewVirtual(2)
+
+	renders := make(chan [][]byte, 1)
+	output.SetOnRenderFunc(func(universes [][]byte) {
+		// Only the latest render is kept.
+		select {
+		case <-renders:
+		default:
+		}
+		renders <- universes
+	})
+
+	patch := &fixture.Patch{
+		Fixtures: []fixture.Fixture{
+			{Name: "par", Profile: "rgb", Channel: 1, Address: 1},
+			{Name: "bar", Profile: "drgb", Channel: 2, Universe: 1, Address: 4},
+		},
+	}
+
+	c, err := New(clk, 16, conn, output, patch, protocol.Auth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.Run(ctx)
+
+	sender := protocol.NewSender(controller(conn.incoming), protocol.Auth{})
+	sendPattern(t, sender, 1, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255})
+	sendPattern(t, sender, 2, color.RGBA{0, 128, 0, 255})
+
+	// The dimmer of the bar takes the brightness of the colour, and its green
+	// channel the colour at full brightness.
+	go clk.SeekStep(0)
+	waitUniverses(t, renders,
+		[]byte{255, 0, 0},
+		[]byte{0, 0, 0, 128, 0, 255, 0},
+	)
+
+	go clk.Advance(1)
+	waitUniverses(t, renders,
+		[]byte{0, 0, 255},
+		[]byte{0, 0, 0, 128, 0, 255, 0},
+	)
+
+	// The pattern of the par loops over its two steps.
+	go clk.SeekStep(10)
+	waitUniverses(t, renders,
+		[]byte{255, 0, 0},
+		[]byte{0, 0, 0, 128, 0, 255, 0},
+	)
+}
+
+func TestClientTiming(t *testing.T) {
+	// The clock barely moves on its own between the steps sought.
+	clk := clock.NewVirtualClock(1)
+	defer clk.Close()
+
+	conn := newFakeConn()
+	output := dmx.NewVirtual(1)
+
+	renders := make(chan [][]byte, 1)
+	output.SetOnRenderFunc(func(universes [][]byte) {
+		select {
+		case <-renders:
+		default:
+		}
+		renders <- universes
+	})
+
+	// The fixtures play the same channel, each at its own rate and swing.
+	patch := RoutesPatch([]Route{
+		{Channel: 1, Address: 1},
+		{Channel: 1, Address: 4, Rate: "1/8"},
+		{Channel: 1, Address: 7, Rate: "1/8", Swing: 0.5},
+	})
+
+	c, err := New(clk, 16, conn, output, patch, protocol.Auth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.Run(ctx)
+
+	sender := protocol.NewSender(controller(conn.incoming), protocol.Auth{})
+	sendPattern(t, sender, 1,
+		color.RGBA{255, 0, 0, 255},
+		color.RGBA{0, 255, 0, 255},
+		color.RGBA{0, 0, 255, 255},
+		color.RGBA{255, 255, 255, 255},
+	)
+
+	// Rendering the pattern means the client follows the clock.
+	waitUniverses(t, renders, []byte{255, 0, 0, 255, 0, 0, 255, 0, 0})
+
+	for _, test := range []struct {
+		step int64
+		want []byte
+	}{
+		{1, []byte{0, 255, 0, 255, 0, 0, 255, 0, 0}},
+		// The second eighth note is swung by half a step, to the fourth
+		// sixteenth note.
+		{2, []byte{0, 0, 255, 0, 255, 0, 255, 0, 0}},
+		{3, []byte{255, 255, 255, 0, 255, 0, 0, 255, 0}},
+		{4, []byte{255, 0, 0, 0, 0, 255, 0, 0, 255}},
+	} {
+		go clk.SeekStep(test.step)
+		waitUniverses(t, renders, test.want)
+	}
+}
+
+func TestClientBadTiming(t *testing.T) {
+	for _, route := range []Route{
+		{Channel: 1, Address: 1, Rate: "1/5"},
+		{Channel: 1, Address: 1, Swing: 2},
+	} {
+		patch := RoutesPatch([]Route{route})
+		if _, err := New(clock.NewVirtualClock(120), 16, newFakeConn(), dmx.NewVirtual(1), patch, protocol.Auth{}); err == nil {
+			t.Fatalf("got no error for route %+v", route)
+		}
+	}
+}
+
+func TestClientBadPatch(t *testing.T) {
+	output := dmx.NewVirtual(1)
+
+	patch := &fixture.Patch{
+		Fixtures: []fixture.Fixture{
+			{Name: "par", Profile: "rgb", Channel: 1, Universe: 1, Address: 1},
+		},
+	}
+
+	if _, err := New(clock.NewVirtualClock(120), 16, newFakeConn(), output, patch, protocol.Auth{}); err == nil {
+		t.Fatal("got no error for a fixture out of the universes of the output")
+	}
+}
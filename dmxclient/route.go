@@ -0,0 +1,76 @@
+package dmxclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"essaim.dev/essaim/fixture"
+)
+
+// Route sends the pattern of an essaim channel to an RGB fixture, whose red,
+// green and blue are on consecutive DMX channels starting at Address. The
+// fixture plays the steps at the given rate and swing, sixteenth notes if
+// empty.
+type Route struct {
+	Channel uint64
+	Address int
+	Rate    string
+	Swing   float64
+}
+
+// ParseRoutes parses a comma-separated list of routes, each written as the
+// essaim channel and the DMX address of its fixture, optionally followed by
+// the rate and swing of its steps: "1:1,2:4:1/8,3:7:1/16:0.3".
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(field), ":")
+		if len(parts) < 2 || len(parts) > 4 {
+			return nil, fmt.Errorf("could not parse route %q: want channel:address[:rate[:swing]]", field)
+		}
+		channel, address := parts[0], parts[1]
+
+		ch, err := strconv.ParseUint(channel, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse channel of route %q: %w", field, err)
+		}
+
+		addr, err := strconv.Atoi(address)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse address of route %q: %w", field, err)
+		}
+
+		route := Route{Channel: ch, Address: addr}
+		if len(parts) > 2 {
+			route.Rate = parts[2]
+		}
+		if len(parts) > 3 {
+			route.Swing, err = strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse swing of route %q: %w", field, err)
+			}
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// RoutesPatch returns the patch of the RGB fixtures of the given routes.
+func RoutesPatch(routes []Route) *fixture.Patch {
+	patch := &fixture.Patch{}
+	for _, r := range routes {
+		patch.Fixtures = append(patch.Fixtures, fixture.Fixture{
+			Profile: "rgb",
+			Channel: r.Channel,
+			Address: r.Address,
+			Rate:    r.Rate,
+			Swing:   r.Swing,
+		})
+	}
+
+	return patch
+}
@@ -0,0 +1,28 @@
+package dmxclient
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("1:1, 2:4:1/8,3:7:1/16t:0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Route{
+		{Channel: 1, Address: 1},
+		{Channel: 2, Address: 4, Rate: "1/8"},
+		{Channel: 3, Address: 7, Rate: "1/16t", Swing: 0.3},
+	}
+	if !slices.Equal(routes, want) {
+		t.Fatalf("got routes %+v, want %+v", routes, want)
+	}
+
+	for _, s := range []string{"1", "a:1", "1:a", "1:1:1/8:a", "1:1:1/8:0.3:1"} {
+		if _, err := ParseRoutes(s); err == nil {
+			t.Fatalf("got no error for routes %q", s)
+		}
+	}
+}
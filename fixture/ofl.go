@@ -0,0 +1,313 @@
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownMode = errors.New("unknown fixture mode")
+	ErrUnsupported = errors.New("unsupported fixture definition")
+	ErrUnresolved  = errors.New("channel kind could not be resolved")
+)
+
+// oflColors maps the colours of the Open Fixture Library emitters to the
+// channel kinds driven by the patterns. The others are left off.
+var oflColors = map[string]string{
+	"Red":        Red,
+	"Green":      Green,
+	"Blue":       Blue,
+	"Cyan":       Cyan,
+	"Magenta":    Magenta,
+	"Yellow":     Yellow,
+	"Amber":      Amber,
+	"White":      White,
+	"Warm White": White,
+	"Cold White": White,
+	"UV":         UV,
+}
+
+// oflFixture is the part of an Open Fixture Library definition used to build
+// profiles, see https://github.com/OpenLightingProject/open-fixture-library.
+type oflFixture struct {
+	Name              string                `json:"name"`
+	AvailableChannels map[string]oflChannel `json:"availableChannels"`
+	Wheels            map[string]oflWheel   `json:"wheels"`
+	Modes             []oflMode             `json:"modes"`
+}
+
+type oflChannel struct {
+	FineChannelAliases []string        `json:"fineChannelAliases"`
+	DefaultValue       any             `json:"defaultValue"`
+	Capability         *oflCapability  `json:"capability"`
+	Capabilities       []oflCapability `json:"capabilities"`
+}
+
+type oflCapability struct {
+	DMXRange      []int   `json:"dmxRange"`
+	Type          string  `json:"type"`
+	Color         string  `json:"color"`
+	Wheel         string  `json:"wheel"`
+	SlotNumber    float64 `json:"slotNumber"`
+	ShutterEffect string  `json:"shutterEffect"`
+}
+
+type oflWheel struct {
+	Slots []oflSlot `json:"slots"`
+}
+
+type oflSlot struct {
+	Type   string   `json:"type"`
+	Colors []string `json:"colors"`
+}
+
+type oflMode struct {
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+	// Channels holds the names of the channels, or null for unused ones. The
+	// matrix insertions are not supported.
+	Channels []json.RawMessage `json:"channels"`
+}
+
+// LoadOFL reads the given mode of an Open Fixture Library definition, or its
+// first mode if empty.
+func LoadOFL(path string, mode string) (*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open fixture definition: %w", err)
+	}
+	defer f.Close()
+
+	return ReadOFL(f, mode)
+}
+
+// ReadOFL converts the given mode of an Open Fixture Library definition to a
+// profile. The channels which are not driven by the colours of the patterns,
+// such as pan and tilt, hold their default value, as do those whose kind could
+// not be resolved, which are listed in the warnings of the profile.
+func ReadOFL(r io.Reader, mode string) (*Profile, error) {
+	def := oflFixture{}
+	if err := json.NewDecoder(r).Decode(&def); err != nil {
+		return nil, fmt.Errorf("could not decode fixture definition: %w", err)
+	}
+
+	m, err := def.mode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// fineOf maps the name of the first fine channel of a 16-bit channel to
+	// the name of its coarse channel.
+	fineOf := make(map[string]string)
+	for name, ch := range def.AvailableChannels {
+		if len(ch.FineChannelAliases) > 0 {
+			fineOf[ch.FineChannelAliases[0]] = name
+		}
+	}
+
+	p := &Profile{
+		Name:     fmt.Sprintf("%s (%s)", def.Name, m.Name),
+		Channels: make([]Channel, len(m.Channels)),
+	}
+
+	// The coarse channels are converted first, so that the fine channels take
+	// their kind wherever they are in the mode.
+	names := make([]*string, len(m.Channels))
+	kinds := make(map[string]string)
+	for idx, raw := range m.Channels {
+		if err := json.Unmarshal(raw, &names[idx]); err != nil {
+			return nil, fmt.Errorf("%w: channel %d of mode %s is not a channel name", ErrUnsupported, idx+1, m.Name)
+		}
+
+		name := names[idx]
+		if name == nil {
+			p.Channels[idx] = Channel{Kind: Fixed}
+			continue
+		}
+		if _, ok := fineOf[*name]; ok {
+			continue
+		}
+
+		available, ok := def.AvailableChannels[*name]
+		if !ok {
+			// Fine channels beyond 16 bits and template channels.
+			p.Channels[idx] = Channel{Kind: Fixed}
+			continue
+		}
+
+		ch, err := def.convert(p, available, *name)
+		if err != nil {
+			p.Warnings = append(p.Warnings, fmt.Errorf("channel %d: %w", idx+1, err))
+		}
+		kinds[*name] = ch.Kind
+		p.Channels[idx] = ch
+	}
+
+	for idx, name := range names {
+		if name == nil {
+			continue
+		}
+		coarse, ok := fineOf[*name]
+		if !ok {
+			continue
+		}
+
+		kind, ok := kinds[coarse]
+		switch {
+		case !ok:
+			p.Channels[idx] = Channel{Kind: Fixed}
+			p.Warnings = append(p.Warnings, fmt.Errorf("channel %d: %w: fine channel %q without its coarse channel %q", idx+1, ErrUnresolved, *name, coarse))
+		case kind == Fixed || kind == Strobe || kind == ColorWheel:
+			p.Channels[idx] = Channel{Kind: Fixed}
+		default:
+			p.Channels[idx] = Channel{Kind: kind, Fine: true}
+		}
+	}
+
+	if err := p.check(); err != nil {
+		return nil, fmt.Errorf("could not convert mode %s: %w", m.Name, err)
+	}
+
+	return p, nil
+}
+
+func (f oflFixture) mode(name string) (oflMode, error) {
+	if len(f.Modes) == 0 {
+		return oflMode{}, fmt.Errorf("%w: no mode", ErrUnsupported)
+	}
+
+	if name == "" {
+		return f.Modes[0], nil
+	}
+
+	names := make([]string, 0, len(f.Modes))
+	for _, m := range f.Modes {
+		if m.Name == name || m.ShortName == name {
+			return m, nil
+		}
+		names = append(names, m.Name)
+	}
+
+	return oflMode{}, fmt.Errorf("%w: %q, want one of %s", ErrUnknownMode, name, strings.Join(names, ", "))
+}
+
+func (ch oflChannel) capabilities() []oflCapability {
+	if ch.Capability != nil {
+		return []oflCapability{*ch.Capability}
+	}
+
+	return ch.Capabilities
+}
+
+// defaultValue returns the default value of the channel when it is given in
+// DMX values rather than as a percentage.
+func (ch oflChannel) defaultValue() uint8 {
+	if v, ok := ch.DefaultValue.(float64); ok {
+		return uint8(min(max(v, 0), math.MaxUint8))
+	}
+
+	return 0
+}
+
+// convert returns the channel driven by the given channel of the definition,
+// adding the slots of its colour wheel to the profile. Intensity channels with
+// other capabilities are not driven, and returned along with ErrUnresolved.
+func (f oflFixture) convert(p *Profile, ch oflChannel, name string) (Channel, error) {
+	caps := ch.capabilities()
+
+	// A single colour wheel is supported, the next ones hold their default
+	// value.
+	if len(p.Wheel) == 0 {
+		if slots := f.wheelSlots(caps, name); len(slots) > 0 {
+			p.Wheel = slots
+			return Channel{Kind: ColorWheel}, nil
+		}
+	}
+
+	if len(caps) == 1 {
+		switch caps[0].Type {
+		case "Intensity":
+			return Channel{Kind: Dimmer}, nil
+		case "ColorIntensity":
+			if kind, ok := oflColors[caps[0].Color]; ok {
+				return Channel{Kind: kind}, nil
+			}
+		}
+	}
+
+	// The shutter is kept open on strobe channels.
+	for _, c := range caps {
+		if c.Type == "ShutterStrobe" && c.ShutterEffect == "Open" && len(c.DMXRange) == 2 {
+			return Channel{Kind: Strobe, Value: uint8(c.DMXRange[0])}, nil
+		}
+	}
+
+	fixed := Channel{Kind: Fixed, Value: ch.defaultValue()}
+	for _, c := range caps {
+		if _, ok := oflColors[c.Color]; c.Type == "Intensity" || c.Type == "ColorIntensity" && ok {
+			return fixed, fmt.Errorf("%w: %q has %d capabilities", ErrUnresolved, name, len(caps))
+		}
+	}
+
+	return fixed, nil
+}
+
+// wheelSlots returns the colour slots selected by the capabilities of a colour
+// wheel channel, each at the middle of its range.
+func (f oflFixture) wheelSlots(caps []oflCapability, name string) []WheelSlot {
+	var slots []WheelSlot
+
+	for _, c := range caps {
+		// Split colours, between two slots, are skipped.
+		if c.Type != "WheelSlot" || len(c.DMXRange) != 2 || c.SlotNumber != math.Trunc(c.SlotNumber) {
+			continue
+		}
+
+		wheel := c.Wheel
+		if wheel == "" {
+			wheel = name
+		}
+
+		col, ok := f.slotColor(wheel, int(c.SlotNumber))
+		if !ok {
+			continue
+		}
+
+		slots = append(slots, WheelSlot{
+			Color: col,
+			Value: uint8((c.DMXRange[0] + c.DMXRange[1]) / 2),
+		})
+	}
+
+	return slots
+}
+
+// slotColor returns the colour of a slot of a wheel, numbered from 1. Open
+// slots let white light through.
+func (f oflFixture) slotColor(wheel string, number int) (Color, bool) {
+	slots := f.Wheels[wheel].Slots
+	if number < 1 || number > len(slots) {
+		return Color{}, false
+	}
+
+	slot := slots[number-1]
+	switch {
+	case slot.Type == "Open":
+		return Color{R: 255, G: 255, B: 255, A: 255}, true
+
+	case slot.Type == "Color" && len(slot.Colors) > 0:
+		col := Color{}
+		if err := col.UnmarshalJSON([]byte(strconv.Quote(slot.Colors[0]))); err != nil {
+			return Color{}, false
+		}
+		return col, true
+	}
+
+	return Color{}, false
+}
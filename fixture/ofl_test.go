@@ -0,0 +1,122 @@
+package fixture
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadOFL(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		mode     string
+		channels []Channel
+		wheel    []WheelSlot
+	}{
+		{
+			name: "first mode",
+			mode: "",
+			channels: []Channel{
+				{Kind: Dimmer, Fine: true}, {Kind: Dimmer}, {Kind: Red}, {Kind: Green}, {Kind: Blue}, {Kind: White},
+				{Kind: Strobe, Value: 8}, {Kind: Fixed, Value: 128}, {Kind: Fixed},
+			},
+		},
+		{
+			name: "short name",
+			mode: "16bit",
+			channels: []Channel{
+				{Kind: Dimmer, Fine: true}, {Kind: Dimmer}, {Kind: Red}, {Kind: Green}, {Kind: Blue}, {Kind: White},
+				{Kind: Strobe, Value: 8}, {Kind: Fixed, Value: 128}, {Kind: Fixed},
+			},
+		},
+		{
+			name:     "colour wheel",
+			mode:     "Wheel",
+			channels: []Channel{{Kind: Dimmer}, {Kind: ColorWheel}, {Kind: Strobe, Value: 8}},
+			wheel:    testWheel,
+		},
+		{
+			name:     "cmy",
+			mode:     "CMY",
+			channels: []Channel{{Kind: Dimmer}, {Kind: Cyan}, {Kind: Magenta}, {Kind: Yellow}, {Kind: ColorWheel}},
+			wheel:    testWheel,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := LoadOFL("testdata/wash.json", test.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(p.Channels, test.channels) {
+				t.Fatalf("got channels %+v, want %+v", p.Channels, test.channels)
+			}
+			if !slices.Equal(p.Wheel, test.wheel) {
+				t.Fatalf("got wheel %+v, want %+v", p.Wheel, test.wheel)
+			}
+			if len(p.Warnings) > 0 {
+				t.Fatalf("got warnings %v", p.Warnings)
+			}
+		})
+	}
+}
+
+func TestLoadOFLRender(t *testing.T) {
+	p, err := LoadOFL("testdata/wash.json", "CMY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The wheel is kept on its open slot, the filters mixing the colour.
+	dst := make([]byte, p.Footprint())
+	p.Render(color.RGBA{G: 128, B: 128, A: 255}, dst)
+	if want := []byte{128, 255, 0, 0, 15}; !bytes.Equal(dst, want) {
+		t.Fatalf("got channels %v, want %v", dst, want)
+	}
+}
+
+func TestLoadOFLUnresolved(t *testing.T) {
+	p, err := LoadOFL("testdata/wash.json", "Unresolved")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fine channel of a channel which is not resolved holds its value
+	// too, without a warning of its own.
+	want := []Channel{{Kind: Red}, {Kind: Fixed}, {Kind: Fixed}, {Kind: Fixed}}
+	if !slices.Equal(p.Channels, want) {
+		t.Fatalf("got channels %+v, want %+v", p.Channels, want)
+	}
+
+	if len(p.Warnings) != 2 {
+		t.Fatalf("got warnings %v, want 2", p.Warnings)
+	}
+	for _, warning := range p.Warnings {
+		if !errors.Is(warning, ErrUnresolved) {
+			t.Fatalf("got warning %v, want %v", warning, ErrUnresolved)
+		}
+	}
+	for idx, prefix := range []string{"channel 4:", "channel 3:"} {
+		if !strings.HasPrefix(p.Warnings[idx].Error(), prefix) {
+			t.Fatalf("got warning %q, want it about %s", p.Warnings[idx], strings.TrimSuffix(prefix, ":"))
+		}
+	}
+}
+
+func TestLoadOFLInvalid(t *testing.T) {
+	if _, err := LoadOFL("testdata/wash.json", "Mode 9"); !errors.Is(err, ErrUnknownMode) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownMode)
+	}
+
+	for _, def := range []string{
+		`{"name": "empty", "modes": []}`,
+		`{"name": "matrix", "modes": [{"name": "pixels", "channels": [{"insert": "matrixChannels"}]}]}`,
+	} {
+		if _, err := ReadOFL(strings.NewReader(def), ""); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("got error %v for %s, want %v", err, def, ErrUnsupported)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"essaim.dev/essaim/clock"
+)
+
+var (
+	ErrUnknownProfile = errors.New("unknown fixture profile")
+	ErrBadAddress     = errors.New("fixture address is out of the universe")
+	ErrOverlap        = errors.New("fixtures overlap")
+	ErrDuplicate      = errors.New("fixture profile defined twice")
+	ErrBadSwing       = errors.New("fixture swing is out of range")
+)
+
+// Fixture is a fixture of the patch, playing the pattern of an essaim
+// channel.
+type Fixture struct {
+	Name    string `json:"name"`
+	Profile string `json:"profile"`
+	// Channel is the essaim channel whose pattern the fixture plays.
+	Channel uint64 `json:"channel"`
+	// Universe is the DMX universe of the fixture, from 0.
+	Universe int `json:"universe,omitempty"`
+	// Address is the first DMX channel of the fixture, from 1.
+	Address int `json:"address"`
+	// Rate is the note division at which the fixture plays the steps of its
+	// pattern, such as "1/8" or "1/16t", sixteenth notes if empty.
+	Rate string `json:"rate,omitempty"`
+	// Swing is the fraction of a step by which the fixture delays odd steps,
+	// between 0 and 1.
+	Swing float64 `json:"swing,omitempty"`
+}
+
+// Timing returns the timing at which the fixture plays the steps of its
+// pattern.
+func (f Fixture) Timing() (clock.Timing, error) {
+	rate := clock.RateSixteenth
+	if f.Rate != "" {
+		var err error
+		rate, err = clock.ParseRate(f.Rate)
+		if err != nil {
+			return clock.Timing{}, err
+		}
+	}
+
+	if f.Swing < 0 || f.Swing > 1 {
+		return clock.Timing{}, fmt.Errorf("%w: %g, want 0 to 1", ErrBadSwing, f.Swing)
+	}
+
+	return clock.NewTiming(rate, f.Swing), nil
+}
+
+func (f Fixture) String() string {
+	name := f.Name
+	if name == "" {
+		name = f.Profile
+	}
+
+	if f.Universe != 0 {
+		return fmt.Sprintf("%s at %d.%d", name, f.Universe, f.Address)
+	}
+
+	return fmt.Sprintf("%s at %d", name, f.Address)
+}
+
+// OFLProfile is a profile imported from a mode of an Open Fixture Library
+// definition.
+type OFLProfile struct {
+	// File is the path of the definition, relative to the patch file.
+	File string `json:"file"`
+	// Mode is the name of the mode, or empty for the first one.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Patch maps fixtures to their addresses in a DMX universe. Profiles defined
+// by the patch take precedence over the builtin ones.
+type Patch struct {
+	Profiles map[string]*Profile   `json:"profiles,omitempty"`
+	OFL      map[string]OFLProfile `json:"ofl,omitempty"`
+	Fixtures []Fixture             `json:"fixtures"`
+}
+
+// LoadPatch reads a patch from a JSON file, such as:
+//
+//	{
+//	  "profiles": {
+//	    "bar": {"name": "RGBW bar", "channels": [{"kind": "dimmer"}, {"kind": "red"}, {"kind": "green"}, {"kind": "blue"}, {"kind": "white"}]}
+//	  },
+//	  "ofl": {
+//	    "head": {"file": "fixtures/robe/robin-600e-spot.json", "mode": "Mode 1"}
+//	  },
+//	  "fixtures": [
+//	    {"name": "left", "profile": "bar", "channel": 1, "address": 1},
+//	    {"name": "par", "profile": "rgb", "channel": 2, "address": 6, "rate": "1/8", "swing": 0.3},
+//	    {"name": "spot", "profile": "head", "channel": 3, "address": 10}
+//	  ]
+//	}
+func LoadPatch(path string) (*Patch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open patch: %w", err)
+	}
+	defer f.Close()
+
+	return ReadPatch(f, filepath.Dir(path))
+}
+
+// ReadPatch reads a patch, importing the Open Fixture Library definitions it
+// references from the given directory.
+func ReadPatch(r io.Reader, dir string) (*Patch, error) {
+	p := &Patch{}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("could not decode patch: %w", err)
+	}
+
+	for name, ofl := range p.OFL {
+		if _, ok := p.Profiles[name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicate, name)
+		}
+
+		path := ofl.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		profile, err := LoadOFL(path, ofl.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("could not import profile %s: %w", name, err)
+		}
+
+		if p.Profiles == nil {
+			p.Profiles = make(map[string]*Profile)
+		}
+		p.Profiles[name] = profile
+	}
+
+	return p, nil
+}
+
+// Profile returns the profile of the given fixture, or nil if it is unknown.
+func (p *Patch) Profile(f Fixture) *Profile {
+	if profile, ok := p.Profiles[f.Profile]; ok {
+		return profile
+	}
+
+	return Builtin[f.Profile]
+}
+
+// Channels returns the essaim channels played by the fixtures, in order.
+func (p *Patch) Channels() []uint64 {
+	var channels []uint64
+	for _, f := range p.Fixtures {
+		if !slices.Contains(channels, f.Channel) {
+			channels = append(channels, f.Channel)
+		}
+	}
+	slices.Sort(channels)
+
+	return channels
+}
+
+// Check makes sure every fixture has a valid profile and timing, and fits in
+// its universe of the given number of channels without sharing any with
+// another fixture.
+func (p *Patch) Check(universeSize int) error {
+	for name, profile := range p.Profiles {
+		if err := profile.check(); err != nil {
+			return fmt.Errorf("invalid profile %s: %w", name, err)
+		}
+	}
+
+	for idx, f := range p.Fixtures {
+		profile := p.Profile(f)
+		if profile == nil {
+			return fmt.Errorf("%w: %q", ErrUnknownProfile, f.Profile)
+		}
+
+		if f.Address < 1 || f.Address+profile.Footprint()-1 > universeSize {
+			return fmt.Errorf("%w: %s, want 1 to %d", ErrBadAddress, f, universeSize-profile.Footprint()+1)
+		}
+
+		if _, err := f.Timing(); err != nil {
+			return fmt.Errorf("invalid timing of %s: %w", f, err)
+		}
+
+		for _, other := range p.Fixtures[:idx] {
+			if f.Universe == other.Universe && f.Address < other.Address+p.Profile(other).Footprint() && other.Address < f.Address+profile.Footprint() {
+				return fmt.Errorf("%w: %s and %s", ErrOverlap, other, f)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package fixture
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadPatch(t *testing.T) {
+	p, err := ReadPatch(strings.NewReader(`{
+		"ofl": {"wash": {"file": "wash.json", "mode": "Wheel"}},
+		"fixtures": [
+			{"name": "left", "profile": "wash", "channel": 1, "address": 1},
+			{"name": "par", "profile": "rgb", "channel": 2, "address": 4, "rate": "1/8", "swing": 0.3}
+		]
+	}`), "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Check(512); err != nil {
+		t.Fatal(err)
+	}
+	if profile := p.Profile(p.Fixtures[0]); profile == nil || profile.Footprint() != 3 {
+		t.Fatalf("got profile %+v for the wash, want its wheel mode", profile)
+	}
+
+	for _, test := range []struct {
+		name    string
+		fixture Fixture
+		err     error
+	}{
+		{"unknown profile", Fixture{Profile: "spot", Address: 1}, ErrUnknownProfile},
+		{"out of the universe", Fixture{Profile: "rgb", Address: 511}, ErrBadAddress},
+		{"overlap", Fixture{Profile: "rgb", Address: 3}, ErrOverlap},
+		{"swing out of range", Fixture{Profile: "rgb", Address: 10, Swing: 1.5}, ErrBadSwing},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			bad := &Patch{Profiles: p.Profiles, Fixtures: append(p.Fixtures[:2:2], test.fixture)}
+			if err := bad.Check(512); !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+
+	if _, err := ReadPatch(strings.NewReader(`{"ofl": {"rgb": {"file": "wash.json", "mode": "CMY"}}, "profiles": {"rgb": {"name": "RGB", "channels": []}}, "fixtures": []}`), "testdata"); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicate)
+	}
+}
@@ -0,0 +1,252 @@
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+const (
+	Dimmer     = "dimmer"
+	Red        = "red"
+	Green      = "green"
+	Blue       = "blue"
+	Cyan       = "cyan"
+	Magenta    = "magenta"
+	Yellow     = "yellow"
+	White      = "white"
+	Amber      = "amber"
+	UV         = "uv"
+	Strobe     = "strobe"
+	ColorWheel = "color_wheel"
+	// Fixed channels always hold their value, as for the mode or the control
+	// channels of a fixture.
+	Fixed = "fixed"
+)
+
+// amberGreen is the share of green in the light of an amber emitter, relative
+// to its red.
+const amberGreen = 0.75
+
+var (
+	ErrUnknownKind = errors.New("unknown channel kind")
+	ErrBadFine     = errors.New("fine channel without its coarse channel")
+	ErrNoWheel     = errors.New("colour wheel channel without colours")
+)
+
+var knownKinds = map[string]bool{
+	Dimmer:     true,
+	Red:        true,
+	Green:      true,
+	Blue:       true,
+	Cyan:       true,
+	Magenta:    true,
+	Yellow:     true,
+	White:      true,
+	Amber:      true,
+	UV:         true,
+	Strobe:     true,
+	ColorWheel: true,
+	Fixed:      true,
+}
+
+// Builtin are the profiles available without being defined in a patch file.
+var Builtin = map[string]*Profile{
+	"rgb": {
+		Name:     "RGB",
+		Channels: []Channel{{Kind: Red}, {Kind: Green}, {Kind: Blue}},
+	},
+	"drgb": {
+		Name:     "Dimmer RGB",
+		Channels: []Channel{{Kind: Dimmer}, {Kind: Red}, {Kind: Green}, {Kind: Blue}},
+	},
+	"rgbw": {
+		Name:     "RGBW",
+		Channels: []Channel{{Kind: Red}, {Kind: Green}, {Kind: Blue}, {Kind: White}},
+	},
+	"dimmer": {
+		Name:     "Dimmer",
+		Channels: []Channel{{Kind: Dimmer}},
+	},
+}
+
+// Channel is a DMX channel of a fixture.
+type Channel struct {
+	Kind string `json:"kind"`
+	// Fine marks the least significant byte of a 16-bit value, whose most
+	// significant byte is on the channel of the same kind without Fine.
+	Fine bool `json:"fine,omitempty"`
+	// Value is the value of the fixed, strobe and UV channels, which are not
+	// driven by the colours of the pattern.
+	Value uint8 `json:"value,omitempty"`
+}
+
+// WheelSlot is a colour of a colour wheel, selected by the given value.
+type WheelSlot struct {
+	Color Color `json:"color"`
+	Value uint8 `json:"value"`
+}
+
+// Profile is the channel layout of a model of fixture.
+type Profile struct {
+	Name     string    `json:"name"`
+	Channels []Channel `json:"channels"`
+	// Wheel lists the colours of the colour wheel, if any.
+	Wheel []WheelSlot `json:"wheel,omitempty"`
+
+	// Warnings lists the channels of an imported definition whose kind could
+	// not be resolved, and which hold a fixed value.
+	Warnings []error `json:"-"`
+}
+
+// Footprint returns the number of DMX channels used by the fixture.
+func (p *Profile) Footprint() int {
+	return len(p.Channels)
+}
+
+func (p *Profile) check() error {
+	coarse := make(map[string]bool)
+	for _, ch := range p.Channels {
+		if !knownKinds[ch.Kind] {
+			return fmt.Errorf("%w: %q", ErrUnknownKind, ch.Kind)
+		}
+		if !ch.Fine {
+			coarse[ch.Kind] = true
+		}
+	}
+
+	for _, ch := range p.Channels {
+		if ch.Fine && !coarse[ch.Kind] {
+			return fmt.Errorf("%w: %s", ErrBadFine, ch.Kind)
+		}
+		if ch.Kind == ColorWheel && len(p.Wheel) == 0 {
+			return ErrNoWheel
+		}
+	}
+
+	return nil
+}
+
+func (p *Profile) has(kind string) bool {
+	for _, ch := range p.Channels {
+		if ch.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mixes returns whether the fixture mixes colours, with emitters or filters.
+func (p *Profile) mixes() bool {
+	return p.has(Red) || p.has(Green) || p.has(Blue) || p.has(Cyan) || p.has(Magenta) || p.has(Yellow)
+}
+
+// Render writes to dst the values of the channels of the fixture showing the
+// given colour. The brightness goes to the dimmer when there is one, and the
+// white and amber emitters take their share of the colour from the others.
+// Cyan, magenta and yellow filters subtract from white light the complement of
+// the colour.
+func (p *Profile) Render(col color.RGBA, dst []byte) {
+	r, g, b := float64(col.R)/255, float64(col.G)/255, float64(col.B)/255
+
+	level := max(r, g, b)
+	if p.has(Dimmer) && level > 0 {
+		r, g, b = r/level, g/level, b/level
+	}
+
+	// Fixtures mixing colours keep their wheel on its white slot.
+	var slot uint8
+	if len(p.Wheel) > 0 {
+		if p.mixes() {
+			slot = p.nearestSlot(1, 1, 1)
+		} else {
+			slot = p.nearestSlot(r, g, b)
+		}
+	}
+	c, m, y := 1-r, 1-g, 1-b
+
+	var w, a float64
+	if p.has(White) {
+		w = min(r, g, b)
+		r, g, b = r-w, g-w, b-w
+	}
+	if p.has(Amber) {
+		a = min(r, g/amberGreen)
+		r, g = r-a, g-a*amberGreen
+	}
+
+	levels := map[string]float64{
+		Dimmer:  level,
+		Red:     r,
+		Green:   g,
+		Blue:    b,
+		Cyan:    c,
+		Magenta: m,
+		Yellow:  y,
+		White:   w,
+		Amber:   a,
+	}
+
+	for idx, ch := range p.Channels {
+		if idx >= len(dst) {
+			return
+		}
+
+		switch ch.Kind {
+		case ColorWheel:
+			dst[idx] = slot
+		case UV, Strobe, Fixed:
+			dst[idx] = ch.Value
+		default:
+			v := uint16(math.Round(min(max(levels[ch.Kind], 0), 1) * math.MaxUint16))
+			if ch.Fine {
+				dst[idx] = byte(v)
+			} else {
+				dst[idx] = byte(v >> 8)
+			}
+		}
+	}
+}
+
+// nearestSlot returns the value of the colour of the wheel closest to the
+// given one.
+func (p *Profile) nearestSlot(r, g, b float64) uint8 {
+	best, bestDistance := p.Wheel[0].Value, math.Inf(1)
+	for _, slot := range p.Wheel {
+		dr := r - float64(slot.Color.R)/255
+		dg := g - float64(slot.Color.G)/255
+		db := b - float64(slot.Color.B)/255
+
+		if d := dr*dr + dg*dg + db*db; d < bestDistance {
+			best, bestDistance = slot.Value, d
+		}
+	}
+
+	return best
+}
+
+// Color is a colour written as "#rrggbb" in patch files.
+type Color color.RGBA
+
+func (c Color) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+}
+
+func (c *Color) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	var r, g, bl uint8
+	if _, err := fmt.Sscanf(strings.TrimPrefix(s, "#"), "%02x%02x%02x", &r, &g, &bl); err != nil {
+		return fmt.Errorf("could not parse colour %q: %w", s, err)
+	}
+	*c = Color{R: r, G: g, B: bl, A: 255}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package fixture
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func channels(kinds ...string) []Channel {
+	chs := make([]Channel, len(kinds))
+	for idx, kind := range kinds {
+		chs[idx] = Channel{Kind: kind}
+	}
+
+	return chs
+}
+
+var testWheel = []WheelSlot{
+	{Color: Color{R: 255, G: 255, B: 255, A: 255}, Value: 15},
+	{Color: Color{R: 255, A: 255}, Value: 47},
+	{Color: Color{G: 255, A: 255}, Value: 111},
+	{Color: Color{B: 255, A: 255}, Value: 143},
+}
+
+func TestProfileRender(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		profile *Profile
+		col     color.RGBA
+		want    []byte
+	}{
+		{
+			name:    "rgb",
+			profile: Builtin["rgb"],
+			col:     color.RGBA{R: 255, G: 128, A: 255},
+			want:    []byte{255, 128, 0},
+		},
+		{
+			name:    "dimmer takes the brightness",
+			profile: Builtin["drgb"],
+			col:     color.RGBA{R: 128, B: 128, A: 255},
+			want:    []byte{128, 255, 0, 255},
+		},
+		{
+			name:    "dimmer off",
+			profile: Builtin["drgb"],
+			col:     color.RGBA{A: 255},
+			want:    []byte{0, 0, 0, 0},
+		},
+		{
+			name:    "white takes the share of every emitter",
+			profile: Builtin["rgbw"],
+			col:     color.RGBA{R: 255, G: 255, B: 128, A: 255},
+			want:    []byte{127, 127, 0, 128},
+		},
+		{
+			name:    "amber takes red and green",
+			profile: &Profile{Channels: channels(Red, Green, Blue, Amber)},
+			col:     color.RGBA{R: 200, G: 150, B: 10, A: 255},
+			want:    []byte{0, 0, 10, 200},
+		},
+		{
+			name:    "cmy subtracts the complement",
+			profile: &Profile{Channels: channels(Cyan, Magenta, Yellow)},
+			col:     color.RGBA{R: 255, G: 51, A: 255},
+			want:    []byte{0, 204, 255},
+		},
+		{
+			name:    "cmy with a dimmer",
+			profile: &Profile{Channels: channels(Dimmer, Cyan, Magenta, Yellow)},
+			col:     color.RGBA{G: 128, B: 128, A: 255},
+			want:    []byte{128, 255, 0, 0},
+		},
+		{
+			name:    "nearest wheel slot",
+			profile: &Profile{Channels: channels(Dimmer, ColorWheel), Wheel: testWheel},
+			col:     color.RGBA{R: 20, G: 200, B: 60, A: 255},
+			want:    []byte{200, 111},
+		},
+		{
+			name:    "open wheel slot",
+			profile: &Profile{Channels: channels(Dimmer, ColorWheel), Wheel: testWheel},
+			col:     color.RGBA{R: 240, G: 220, B: 230, A: 255},
+			want:    []byte{240, 15},
+		},
+		{
+			name:    "wheel kept white when mixing",
+			profile: &Profile{Channels: channels(Red, Green, Blue, ColorWheel), Wheel: testWheel},
+			col:     color.RGBA{R: 255, A: 255},
+			want:    []byte{255, 0, 0, 15},
+		},
+		{
+			name: "fine channels",
+			profile: &Profile{Channels: []Channel{
+				{Kind: Red}, {Kind: Red, Fine: true}, {Kind: Green}, {Kind: Blue}, {Kind: Blue, Fine: true},
+			}},
+			col:  color.RGBA{R: 0x12, G: 0x34, B: 0xff, A: 255},
+			want: []byte{0x12, 0x12, 0x34, 0xff, 0xff},
+		},
+		{
+			name: "fine dimmer",
+			profile: &Profile{Channels: []Channel{
+				{Kind: Dimmer, Fine: true}, {Kind: Dimmer}, {Kind: Red}, {Kind: Red, Fine: true},
+			}},
+			col:  color.RGBA{R: 0x80, A: 255},
+			want: []byte{0x80, 0x80, 0xff, 0xff},
+		},
+		{
+			name: "fixed, strobe and uv values",
+			profile: &Profile{Channels: []Channel{
+				{Kind: Fixed, Value: 7}, {Kind: Strobe, Value: 8}, {Kind: UV, Value: 9}, {Kind: Red},
+			}},
+			col:  color.RGBA{R: 255, G: 255, B: 255, A: 255},
+			want: []byte{7, 8, 9, 255},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.profile.check(); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := make([]byte, test.profile.Footprint())
+			test.profile.Render(test.col, dst)
+			if !bytes.Equal(dst, test.want) {
+				t.Fatalf("got channels %v, want %v", dst, test.want)
+			}
+		})
+	}
+}
+
+func TestProfileRenderShort(t *testing.T) {
+	// The channels past the end of the universe are not written.
+	dst := make([]byte, 2)
+	Builtin["rgb"].Render(color.RGBA{R: 1, G: 2, B: 3, A: 255}, dst)
+	if want := []byte{1, 2}; !bytes.Equal(dst, want) {
+		t.Fatalf("got channels %v, want %v", dst, want)
+	}
+}
@@ -9,6 +9,7 @@ require (
 	github.com/ziutek/ftdi v0.0.2-0.20221004094702-6d7dbc95c863
 	golang.org/x/exp/shiny v0.0.0-20241009180824-f66d83c29e7c
 	golang.org/x/mobile v0.0.0-20231127183840-76ac6878050a
+	golang.org/x/net v0.19.0
 )
 
 require (
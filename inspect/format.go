@@ -0,0 +1,119 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+// jsonMessage is the JSON view of a message, reusing the JSON representation
+// of the generated messages.
+type jsonMessage struct {
+	At       string          `json:"at"`
+	From     string          `json:"from"`
+	Size     int             `json:"size"`
+	Type     string          `json:"type,omitempty"`
+	Rate     float64         `json:"rate"`
+	Header   json.RawMessage `json:"header,omitempty"`
+	Pattern  json.RawMessage `json:"pattern,omitempty"`
+	Announce json.RawMessage `json:"announce,omitempty"`
+	State    json.RawMessage `json:"state,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// JSON returns the message as a single line of JSON.
+func (m Message) JSON() ([]byte, error) {
+	v := jsonMessage{
+		At:   m.At.Format("2006-01-02T15:04:05.000000Z07:00"),
+		From: m.From.String(),
+		Size: m.Size,
+		Rate: m.Rate,
+	}
+
+	if m.Header != nil {
+		v.Type = m.Header.Type.String()
+		v.Header = json.RawMessage(m.Header.String())
+	}
+	if m.Pattern != nil {
+		v.Pattern = json.RawMessage(m.Pattern.String())
+	}
+	if m.Announce != nil {
+		v.Announce = json.RawMessage(m.Announce.String())
+	}
+	if m.State != nil {
+		v.State = json.RawMessage(m.State.String())
+	}
+	if m.Err != nil {
+		v.Error = m.Err.Error()
+	}
+
+	return json.Marshal(v)
+}
+
+// Text returns the message in a human-readable form, with the steps of the
+// patterns as colour swatches when colors is set, or as hex codes otherwise.
+func (m Message) Text(colors bool) string {
+	sb := strings.Builder{}
+
+	fmt.Fprintf(&sb, "%s %s", m.At.Format("15:04:05.000"), m.From)
+
+	if m.Header == nil {
+		fmt.Fprintf(&sb, " %d bytes", m.Size)
+	} else {
+		fmt.Fprintf(&sb, " %s from %08x seq %d, %d bytes, %.1f/s",
+			strings.TrimPrefix(m.Header.Type.String(), "MESSAGE_TYPE_"), m.Header.Sender, m.Header.Sequence, m.Size, m.Rate)
+		if m.Header.Flags&essaimbp.FLAG_AUTHENTICATED != 0 {
+			sb.WriteString(", signed")
+		}
+	}
+
+	if m.Err != nil {
+		fmt.Fprintf(&sb, "\n  error: %s", m.Err)
+	}
+
+	if m.Pattern != nil {
+		fmt.Fprintf(&sb, "\n  channel %d: %s", m.Pattern.Channel, swatches(*m.Pattern, colors))
+	}
+
+	if m.Announce != nil {
+		a := m.Announce
+		fmt.Fprintf(&sb, "\n  node %08x %s on channel %d, version %d, up %ds, step %d, %s",
+			a.Node, strings.TrimPrefix(a.Role.String(), "NODE_ROLE_"), a.Channel, a.Version, a.Uptime, a.Step,
+			strings.TrimPrefix(a.Health.String(), "NODE_HEALTH_"))
+	}
+
+	if m.State != nil {
+		fmt.Fprintf(&sb, "\n  active channel %d, mode %d", m.State.ActiveChannel, m.State.Mode)
+		for ch, state := range m.State.Channels {
+			fmt.Fprintf(&sb, "\n  channel %d: %s", ch, swatches(state.Pattern, colors))
+			if state.Live != 0 {
+				sb.WriteString(" (live)")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+func swatches(p essaimbp.Pattern, colors bool) string {
+	if p.Length == 0 {
+		return "empty"
+	}
+
+	sb := strings.Builder{}
+	for idx, step := range p.Steps[:min(int(p.Length), len(p.Steps))] {
+		if colors {
+			fmt.Fprintf(&sb, "\x1b[48;2;%d;%d;%dm  \x1b[0m", step.R, step.G, step.B)
+			continue
+		}
+
+		if idx > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%02x%02x%02x", step.R, step.G, step.B)
+	}
+
+	return sb.String()
+}
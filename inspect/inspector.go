@@ -0,0 +1,155 @@
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/presence"
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	// rateSmoothing is the weight of the last interval in the average
+	// interval between the frames of a sender.
+	rateSmoothing = 0.2
+)
+
+// Message is the decoded view of a frame seen on the network.
+type Message struct {
+	At   time.Time
+	From netip.AddrPort
+	Size int
+
+	// Header is nil when the frame is too short to hold one.
+	Header *essaimbp.Header
+
+	// Only the payload of the type of the message is set, if any.
+	Pattern  *essaimbp.Pattern
+	Announce *essaimbp.Announce
+	State    *essaimbp.State
+
+	// Rate is the number of frames per second of the sender of the message.
+	Rate float64
+
+	// Err is set when the frame could not be decoded, failed authentication
+	// or is not newer than the last one of its sender.
+	Err error
+}
+
+// Concerns returns whether the message carries the pattern of the given
+// channel, or is not related to any.
+func (m Message) Concerns(ch uint64) bool {
+	switch {
+	case m.Pattern != nil:
+		return m.Pattern.Channel == 0 || m.Pattern.Channel == ch
+	case m.Announce != nil:
+		return m.Announce.Channel == 0 || m.Announce.Channel == ch
+	default:
+		return true
+	}
+}
+
+// Inspector decodes every frame it is given, including those a node would
+// drop, and reports why they would be.
+type Inspector struct {
+	receiver *protocol.Receiver
+
+	// intervals is the average interval between the frames of each sender.
+	intervals map[uint32]time.Duration
+	lastSeen  map[uint32]time.Time
+}
+
+func NewInspector(auth protocol.Auth) *Inspector {
+	return &Inspector{
+		receiver:  protocol.NewReceiver(auth),
+		intervals: make(map[uint32]time.Duration),
+		lastSeen:  make(map[uint32]time.Time),
+	}
+}
+
+// Stats returns the counters of the frames inspected.
+func (i *Inspector) Stats() protocol.Stats {
+	return i.receiver.Stats()
+}
+
+func (i *Inspector) Inspect(b []byte, from netip.AddrPort, at time.Time) Message {
+	m := Message{
+		At:   at,
+		From: from,
+		Size: len(b),
+	}
+
+	if len(b) >= protocol.HeaderSize {
+		m.Header = &essaimbp.Header{}
+		m.Header.Decode(b[:protocol.HeaderSize])
+	}
+
+	frame, err := protocol.Decode(b)
+	if err != nil && !errors.Is(err, protocol.ErrUnknownType) {
+		m.Err = err
+		return m
+	}
+
+	m.Rate = i.rate(frame.Header.Sender, at)
+
+	if _, err := i.receiver.Receive(b); err != nil && !errors.Is(err, protocol.ErrUnknownType) {
+		m.Err = err
+	}
+
+	if err := m.decodePayload(frame); err != nil {
+		m.Err = errors.Join(m.Err, err)
+	}
+
+	return m
+}
+
+func (m *Message) decodePayload(frame protocol.Frame) error {
+	switch frame.Header.Type {
+	case essaimbp.MESSAGE_TYPE_PATTERN:
+		if len(frame.Payload) != int(essaimbp.BYTES_LENGTH_PATTERN) {
+			return fmt.Errorf("%w: got %d bytes, want %d", pattern.ErrBadSize, len(frame.Payload), essaimbp.BYTES_LENGTH_PATTERN)
+		}
+		m.Pattern = &essaimbp.Pattern{}
+		m.Pattern.Decode(frame.Payload)
+
+	case essaimbp.MESSAGE_TYPE_ANNOUNCE:
+		announce, err := presence.DecodeAnnounce(frame.Payload)
+		if err != nil {
+			return err
+		}
+		m.Announce = &announce
+
+	case essaimbp.MESSAGE_TYPE_STATE:
+		if len(frame.Payload) != int(essaimbp.BYTES_LENGTH_STATE) {
+			return fmt.Errorf("%w: got %d bytes, want %d", pattern.ErrBadSize, len(frame.Payload), essaimbp.BYTES_LENGTH_STATE)
+		}
+		m.State = &essaimbp.State{}
+		m.State.Decode(frame.Payload)
+	}
+
+	return nil
+}
+
+func (i *Inspector) rate(sender uint32, at time.Time) float64 {
+	last, ok := i.lastSeen[sender]
+	i.lastSeen[sender] = at
+	if !ok {
+		return 0
+	}
+
+	interval := at.Sub(last)
+	if avg, ok := i.intervals[sender]; ok {
+		interval = time.Duration(float64(avg)*(1-rateSmoothing) + float64(interval)*rateSmoothing)
+	}
+	i.intervals[sender] = interval
+
+	if interval <= 0 {
+		return 0
+	}
+
+	return float64(time.Second) / float64(interval)
+}
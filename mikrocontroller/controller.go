@@ -5,14 +5,17 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"net"
-	"net/netip"
+	"math"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"essaim.dev/essaim/clock"
 	"essaim.dev/essaim/pattern"
+	"essaim.dev/essaim/presence"
+	"essaim.dev/essaim/protocol"
+	"essaim.dev/essaim/transport"
 	"essaim.dev/mikro"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
@@ -22,9 +25,15 @@ import (
 const (
 	patternsCount = 16
 	channelsCount = 4
+	padsCount     = 16
 
 	controllerRefreshRate = time.Duration(time.Millisecond * 50)
 	publishRefreshRate    = time.Duration(time.Second)
+
+	tempoStep = 1.0
+	minTempo  = 20.0
+	maxTempo  = 999.0
+	nudgeStep = time.Duration(time.Millisecond * 10)
 )
 
 var (
@@ -99,7 +108,14 @@ const (
 type Controller struct {
 	device *mikro.Mk3
 	clock  clock.Clock
-	conn   *net.UDPConn
+	conn   transport.Conn
+	sender *protocol.Sender
+	auth   protocol.Auth
+
+	registry *presence.Registry
+
+	played   [channelsCount]played
+	playedMu sync.RWMutex
 
 	activeChannel   atomic.Uint64
 	patternChannels [][]*pattern.ColorPattern
@@ -108,7 +124,13 @@ type Controller struct {
 	mode   PadMode
 	modeMu sync.RWMutex
 
-	currentStep atomic.Int32
+	// currentStep is the clock step, each pattern plays it modulo its length.
+	currentStep atomic.Int64
+	stepCount   int
+	activePage  atomic.Int32
+
+	bpm   float64
+	bpmMu sync.RWMutex
 
 	picked   mikro.Color
 	pickedMu sync.RWMutex
@@ -117,27 +139,25 @@ type Controller struct {
 	livePressedMu sync.RWMutex
 }
 
-func NewController(clock clock.Clock, stepCount int, addr netip.AddrPort) (*Controller, error) {
+func NewController(clock clock.Clock, stepCount int, conn transport.Conn, auth protocol.Auth) (*Controller, error) {
 	dev, err := mikro.OpenMk3()
 	if err != nil {
 		return nil, fmt.Errorf("could not open mikro device: %w", err)
 	}
 
-	conn, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(addr))
-	if err != nil {
-		return nil, fmt.Errorf("could not dial udp address: %w", err)
-	}
-	conn.SetReadBuffer(512)
-
 	c := &Controller{
 		clock:           clock,
 		device:          dev,
 		conn:            conn,
+		sender:          protocol.NewSender(conn, auth),
+		auth:            auth,
+		registry:        presence.NewRegistry(),
 		activeChannel:   atomic.Uint64{},
 		patternChannels: make([][]*pattern.ColorPattern, channelsCount),
 		mode:            PadModeColor,
 		activePattern:   atomic.Int32{},
-		currentStep:     atomic.Int32{},
+		currentStep:     atomic.Int64{},
+		stepCount:       min(max(stepCount, pattern.MinSteps), pattern.MaxSteps),
 		picked:          mikro.ColorWhite,
 		livePressed:     make(map[mikro.Pad]uint16, 16),
 	}
@@ -145,10 +165,12 @@ func NewController(clock clock.Clock, stepCount int, addr netip.AddrPort) (*Cont
 	for idx := range c.patternChannels {
 		c.patternChannels[idx] = make([]*pattern.ColorPattern, patternsCount)
 		for patternIdx := range c.patternChannels[idx] {
-			c.patternChannels[idx][patternIdx] = pattern.NewColorPattern(stepCount)
+			c.patternChannels[idx][patternIdx] = pattern.NewColorPattern(c.stepCount)
 		}
 	}
 
+	c.registry.SetOnChangeFunc(c.onNodeChanged)
+
 	return c, nil
 }
 
@@ -166,7 +188,13 @@ func (c *Controller) Run(ctx context.Context) error {
 		deviceErr <- c.device.Run(ctx)
 	}()
 
-	ticks := c.clock.Tick()
+	go func() {
+		if err := c.listen(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("stopped listening to nodes: %s\n", err)
+		}
+	}()
+
+	events := c.clock.Events()
 
 	refreshController := time.NewTicker(controllerRefreshRate)
 	defer refreshController.Stop()
@@ -183,16 +211,20 @@ func (c *Controller) Run(ctx context.Context) error {
 		case err := <-deviceErr:
 			return fmt.Errorf("device stopped running with error: %w", err)
 
-		case tick := <-ticks:
-			c.currentStep.Store(int32(tick % 16))
+		case event, ok := <-events:
+			if !ok {
+				return clock.ErrClosed
+			}
+			c.currentStep.Store(event.Step)
+			if c.setBPM(event.BPM) {
+				go c.updateScreen()
+			}
 
 		case <-refreshController.C:
 			c.renderController()
 
 		case <-refreshPublish.C:
-			if c.activeChannel.Load() == 0 {
-				go c.publishActivePattern()
-			}
+			go c.publishState()
 		}
 	}
 }
@@ -238,6 +270,18 @@ func (c *Controller) setButtonLights(lights *mikro.Lights) {
 
 	lights.Buttons[mikro.ButtonArrowRight] = mikro.IntensityMedium
 	lights.Buttons[mikro.ButtonArrowLeft] = mikro.IntensityMedium
+
+	if _, ok := c.clock.(clock.TempoSetter); ok {
+		lights.Buttons[mikro.ButtonTempo] = mikro.IntensityMedium
+	}
+
+	if _, ok := c.clock.(clock.Tapper); ok {
+		lights.Buttons[mikro.ButtonTap] = mikro.IntensityMedium
+		lights.Buttons[mikro.ButtonRestart] = mikro.IntensityMedium
+		if c.currentStep.Load()%clock.StepsPerBeat == 0 {
+			lights.Buttons[mikro.ButtonTap] = mikro.IntensityHigh
+		}
+	}
 }
 
 func (c *Controller) onPadPressed(msg mikro.PadMessage) {
@@ -275,14 +319,18 @@ func (c *Controller) onPadPressedInStepMode(msg mikro.PadMessage) {
 	}
 
 	pattern := c.patternChannels[c.activeChannel.Load()][c.activePattern.Load()]
+	step := c.pageStep(int(msg.Pad()))
 
-	patternColor, _ := pattern.ColorAt(int(msg.Pad()))
+	patternColor, ok := pattern.ColorAt(step)
+	if !ok {
+		return
+	}
 	padColor := mikro.Color(padPalette.Index(patternColor))
 
 	if padColor == mikro.ColorOff {
-		pattern.SetColorAt(int(msg.Pad()), padColors[c.pickedColor()])
+		pattern.SetColorAt(step, padColors[c.pickedColor()])
 	} else {
-		pattern.SetColorAt(int(msg.Pad()), padColors[mikro.ColorOff])
+		pattern.SetColorAt(step, padColors[mikro.ColorOff])
 	}
 }
 
@@ -305,7 +353,29 @@ func (c *Controller) onPadPressedInLiveMode(msg mikro.PadMessage) {
 }
 
 func (c *Controller) onButtonPressed(msg mikro.ButtonMessage) {
-	for _, btn := range msg.PressedButtons() {
+	pressed := msg.PressedButtons()
+	if slices.Contains(pressed, mikro.ButtonTempo) {
+		c.onTempoButtonsPressed(pressed)
+		return
+	}
+
+	if tapper, ok := c.clock.(clock.Tapper); ok {
+		switch {
+		case slices.Contains(pressed, mikro.ButtonTap):
+			tapper.Tap()
+			return
+		case slices.Contains(pressed, mikro.ButtonRestart):
+			tapper.Resync()
+			return
+		}
+	}
+
+	if slices.Contains(pressed, mikro.ButtonShift) {
+		c.onPageButtonsPressed(pressed)
+		return
+	}
+
+	for _, btn := range pressed {
 		switch btn {
 		case mikro.ButtonPadMode:
 			c.setPadMode(PadModeColor)
@@ -325,6 +395,66 @@ func (c *Controller) onButtonPressed(msg mikro.ButtonMessage) {
 	}
 }
 
+// onTempoButtonsPressed lets the controller act as the tempo master: while the
+// tempo button is held, the arrows propose a new tempo to the clock, or nudge
+// its beat when shift is held too.
+func (c *Controller) onTempoButtonsPressed(pressed []mikro.Button) {
+	if tapper, ok := c.clock.(clock.Tapper); ok && slices.Contains(pressed, mikro.ButtonShift) {
+		for _, btn := range pressed {
+			switch btn {
+			case mikro.ButtonArrowRight:
+				tapper.Nudge(nudgeStep)
+			case mikro.ButtonArrowLeft:
+				tapper.Nudge(-nudgeStep)
+			}
+		}
+		return
+	}
+
+	setter, ok := c.clock.(clock.TempoSetter)
+	if !ok {
+		return
+	}
+
+	bpm := setter.BPM()
+	for _, btn := range pressed {
+		switch btn {
+		case mikro.ButtonArrowRight:
+			bpm += tempoStep
+		case mikro.ButtonArrowLeft:
+			bpm -= tempoStep
+		}
+	}
+
+	bpm = min(max(bpm, minTempo), maxTempo)
+	if bpm != setter.BPM() {
+		setter.SetTempo(bpm)
+	}
+}
+
+// onPageButtonsPressed moves through the pages of steps shown on the pads when
+// the patterns are longer than the pads, while shift is held.
+func (c *Controller) onPageButtonsPressed(pressed []mikro.Button) {
+	lastPage := int32((c.stepCount - 1) / padsCount)
+
+	for _, btn := range pressed {
+		switch btn {
+		case mikro.ButtonArrowRight:
+			c.activePage.Store(min(c.activePage.Load()+1, lastPage))
+			go c.updateScreen()
+		case mikro.ButtonArrowLeft:
+			c.activePage.Store(max(c.activePage.Load()-1, 0))
+			go c.updateScreen()
+		}
+	}
+}
+
+// pageStep returns the pattern step edited by the given pad on the active
+// page.
+func (c *Controller) pageStep(pad int) int {
+	return int(c.activePage.Load())*padsCount + pad
+}
+
 func (c *Controller) incrementActiveChannel() {
 	ch := c.activeChannel.Load()
 	if (ch + 1) < channelsCount {
@@ -374,16 +504,24 @@ func (c *Controller) renderColorModePads(lights *mikro.Lights) {
 
 func (c *Controller) renderStepModePads(lights *mikro.Lights) {
 	pattern := c.patternChannels[c.activeChannel.Load()][c.activePattern.Load()]
+	playing := pattern.StepAt(c.currentStep.Load())
 
 	for idx := range lights.Pads {
+		step := c.pageStep(idx)
+
+		col, ok := pattern.ColorAt(step)
+		if !ok {
+			lights.Pads[idx] = mikro.ColoredLight{}
+			continue
+		}
+
 		level := mikro.ColorLevelHigh
-		if idx == int(c.currentStep.Load()) {
+		if step == playing {
 			level = mikro.ColorLevelFaded
 		}
 
-		col, _ := pattern.ColorAt(idx)
 		color := mikro.Color(padPalette.Index(col))
-		if color == mikro.ColorOff && idx == int(c.currentStep.Load()) {
+		if color == mikro.ColorOff && step == playing {
 			level = mikro.ColorLevelLow
 			color = mikro.ColorWhite
 		}
@@ -407,7 +545,7 @@ func (c *Controller) renderPatternModePads(lights *mikro.Lights) {
 
 		p := c.patternChannels[c.activeChannel.Load()][idx]
 
-		patternColor, _ := p.ColorAt(int(step))
+		patternColor, _ := p.ColorAt(p.StepAt(step))
 		padColor := mikro.Color(padPalette.Index(patternColor))
 		if idx == int(activePattern) && padColor == mikro.ColorOff {
 			level = mikro.ColorLevelLow
@@ -442,6 +580,27 @@ func (c *Controller) renderLiveModePads(lights *mikro.Lights) {
 	}
 }
 
+func (c *Controller) currentBPM() float64 {
+	c.bpmMu.RLock()
+	defer c.bpmMu.RUnlock()
+
+	return c.bpm
+}
+
+// setBPM records the tempo of the clock and reports whether it changed enough
+// to be displayed.
+func (c *Controller) setBPM(bpm float64) bool {
+	c.bpmMu.Lock()
+	defer c.bpmMu.Unlock()
+
+	if math.Abs(bpm-c.bpm) < 0.1 {
+		return false
+	}
+
+	c.bpm = bpm
+	return true
+}
+
 func (c *Controller) pickedColor() mikro.Color {
 	c.pickedMu.RLock()
 	defer c.pickedMu.RUnlock()
@@ -457,9 +616,11 @@ func (c *Controller) setPickedColor(color mikro.Color) {
 }
 
 func (c *Controller) publishActivePattern() error {
-	_, err := c.conn.Write(c.currentPattern().Encode(c.activeChannel.Load()))
+	ch := c.activeChannel.Load()
+
+	err := c.publish(ch, c.currentPattern().Message(ch), c.padMode() == PadModeLive)
 	if err != nil {
-		return fmt.Errorf("could not write active pattern to udp conn: %w", err)
+		return fmt.Errorf("could not publish active pattern: %w", err)
 	}
 
 	return nil
@@ -488,7 +649,7 @@ func (c *Controller) livePattern() *pattern.ColorPattern {
 
 	col := blendRGBAColors(pressedColors)
 
-	p := pattern.NewColorPattern(16)
+	p := pattern.NewColorPattern(c.stepCount)
 	for idx := range p.Steps() {
 		p.SetColorAt(idx, col)
 	}
@@ -496,6 +657,24 @@ func (c *Controller) livePattern() *pattern.ColorPattern {
 	return p
 }
 
+// Nodes returns the nodes of the show seen by the controller.
+func (c *Controller) Nodes() []presence.Node {
+	return c.registry.Nodes()
+}
+
+func (c *Controller) onNodeChanged(node presence.Node) {
+	switch {
+	case node.Online:
+		fmt.Printf("%s is online, health: %s\n", node, node.Health)
+	case node.Forgotten:
+		fmt.Printf("%s is forgotten, last seen %s ago\n", node, time.Since(node.LastSeen).Round(time.Second))
+	default:
+		fmt.Printf("%s went silent, last seen %s ago\n", node, time.Since(node.LastSeen).Round(time.Second))
+	}
+
+	go c.updateScreen()
+}
+
 func (c *Controller) updateScreen() {
 	deviceImage := image.NewGray(image.Rect(0, 0, 128, 32))
 	point := fixed.Point26_6{
@@ -508,7 +687,13 @@ func (c *Controller) updateScreen() {
 		Face: basicfont.Face7x13,
 		Dot:  point,
 	}
-	fontDrawer.DrawString(fmt.Sprintf("chan: %d", c.activeChannel.Load()))
+	fontDrawer.DrawString(fmt.Sprintf("chan: %d  page: %d/%d", c.activeChannel.Load(), c.activePage.Load()+1, (c.stepCount-1)/padsCount+1))
+	fontDrawer.Dot = fixed.Point26_6{
+		X: fixed.I(10),
+		Y: fixed.I(24),
+	}
+	online, total := c.registry.Count()
+	fontDrawer.DrawString(fmt.Sprintf("%.1fbpm nodes %d/%d", c.currentBPM(), online, total))
 	if err := c.device.SetScreen(deviceImage); err != nil {
 		fmt.Printf("could not update device screen: %s\n", err)
 	}
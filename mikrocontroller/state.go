@@ -0,0 +1,140 @@
+package mikrocontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/presence"
+	"essaim.dev/essaim/protocol"
+)
+
+// played is the last pattern published on a channel.
+type played struct {
+	pattern       essaimbp.Pattern
+	activePattern uint8
+	live          bool
+}
+
+// publish sends a pattern to the given channel and records it as played there,
+// or on every channel for channel 0 which every node listens to.
+func (c *Controller) publish(ch uint64, message essaimbp.Pattern, live bool) error {
+	c.playedMu.Lock()
+	defer c.playedMu.Unlock()
+
+	p := played{
+		pattern:       message,
+		activePattern: uint8(c.activePattern.Load()),
+		live:          live,
+	}
+	if ch == 0 {
+		for idx := range c.played {
+			c.played[idx] = p
+		}
+	} else if ch < uint64(len(c.played)) {
+		c.played[ch] = p
+	}
+
+	if err := c.sender.Send(essaimbp.MESSAGE_TYPE_PATTERN, message.Encode()); err != nil {
+		return fmt.Errorf("could not send pattern: %w", err)
+	}
+
+	return nil
+}
+
+// publishState sends the complete state of the show, so that the nodes which
+// joined late recover the pattern of their channel.
+func (c *Controller) publishState() error {
+	c.playedMu.Lock()
+	defer c.playedMu.Unlock()
+
+	state := essaimbp.State{
+		ActiveChannel: uint8(c.activeChannel.Load()),
+		Mode:          uint8(c.padMode()),
+	}
+	for ch, p := range c.played {
+		state.Channels[ch] = essaimbp.ChannelState{
+			Pattern:       p.pattern,
+			ActivePattern: p.activePattern,
+		}
+		if p.live {
+			state.Channels[ch].Live = 1
+		}
+	}
+
+	if err := c.sender.Send(essaimbp.MESSAGE_TYPE_STATE, state.Encode()); err != nil {
+		return fmt.Errorf("could not send state: %w", err)
+	}
+
+	return nil
+}
+
+// listen handles the messages sent by the nodes: their announces and their
+// requests for the state of the show.
+func (c *Controller) listen(ctx context.Context) error {
+	go c.expireNodes(ctx)
+
+	receiver := protocol.NewReceiver(c.auth)
+
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		n, from, err := c.conn.ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error while reading frames: %w", err)
+		}
+
+		if err := c.handleFrame(receiver, b[:n], from.Addr()); err != nil {
+			fmt.Printf("discarding packet: %s\n", err)
+		}
+	}
+}
+
+// expireNodes regularly looks for silent nodes, which are noticed even when
+// nothing is received anymore.
+func (c *Controller) expireNodes(ctx context.Context) {
+	t := time.NewTicker(presence.HeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			c.registry.Expire(now)
+		}
+	}
+}
+
+func (c *Controller) handleFrame(receiver *protocol.Receiver, b []byte, from netip.Addr) error {
+	frame, err := receiver.Receive(b)
+	if errors.Is(err, protocol.ErrUnknownType) || errors.Is(err, protocol.ErrStale) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not decode frame: %w", err)
+	}
+
+	switch frame.Header.Type {
+	case essaimbp.MESSAGE_TYPE_ANNOUNCE:
+		announce, err := presence.DecodeAnnounce(frame.Payload)
+		if err != nil {
+			return fmt.Errorf("could not decode announce: %w", err)
+		}
+		c.registry.Update(announce, from, time.Now())
+
+	case essaimbp.MESSAGE_TYPE_STATE_REQUEST:
+		go func() {
+			if err := c.publishState(); err != nil {
+				fmt.Printf("could not answer state request: %s\n", err)
+			}
+		}()
+	}
+
+	return nil
+}
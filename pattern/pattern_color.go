@@ -1,18 +1,34 @@
 package pattern
 
 import (
+	"errors"
+	"fmt"
 	"image/color"
 	"sync"
 
 	"essaim.dev/essaim/api/essaimbp"
 )
 
+const (
+	MinSteps = 1
+	MaxSteps = len(essaimbp.Pattern{}.Steps)
+)
+
+var (
+	ErrBadSize   = errors.New("pattern message has an unexpected size")
+	ErrBadLength = errors.New("pattern message has an invalid step count")
+)
+
 type ColorPattern struct {
 	steps   []color.RGBA
 	stepsMu sync.RWMutex
 }
 
+// NewColorPattern returns a pattern of the given number of steps, bounded
+// between MinSteps and MaxSteps.
 func NewColorPattern(steps int) *ColorPattern {
+	steps = min(max(steps, MinSteps), MaxSteps)
+
 	p := &ColorPattern{
 		steps: make([]color.RGBA, steps),
 	}
@@ -31,6 +47,31 @@ func (p *ColorPattern) Steps() []color.RGBA {
 	return p.steps
 }
 
+func (p *ColorPattern) Len() int {
+	p.stepsMu.RLock()
+	defer p.stepsMu.RUnlock()
+
+	return len(p.steps)
+}
+
+// StepAt returns the index of the pattern step played at the given clock step,
+// looping over the pattern.
+func (p *ColorPattern) StepAt(step int64) int {
+	p.stepsMu.RLock()
+	defer p.stepsMu.RUnlock()
+
+	if len(p.steps) == 0 {
+		return 0
+	}
+
+	idx := step % int64(len(p.steps))
+	if idx < 0 {
+		idx += int64(len(p.steps))
+	}
+
+	return int(idx)
+}
+
 func (p *ColorPattern) ColorAt(step int) (color.Color, bool) {
 	p.stepsMu.RLock()
 	defer p.stepsMu.RUnlock()
@@ -55,11 +96,19 @@ func (p *ColorPattern) SetColorAt(step int, c color.RGBA) bool {
 }
 
 func (p *ColorPattern) Encode(ch uint64) []byte {
+	message := p.Message(ch)
+
+	return message.Encode()
+}
+
+// Message returns the pattern message sent to the given channel.
+func (p *ColorPattern) Message(ch uint64) essaimbp.Pattern {
 	p.stepsMu.RLock()
 	defer p.stepsMu.RUnlock()
 
 	message := essaimbp.Pattern{
 		Channel: ch,
+		Length:  uint8(len(p.steps)),
 	}
 
 	for idx, stepColor := range p.steps {
@@ -71,19 +120,54 @@ func (p *ColorPattern) Encode(ch uint64) []byte {
 		}
 	}
 
-	return message.Encode()
+	return message
 }
 
-func (p *ColorPattern) Decode(b []byte, ch uint64) {
+// Decode updates the pattern from an encoded pattern message, unless the
+// message targets another channel.
+func (p *ColorPattern) Decode(b []byte, ch uint64) error {
+	if len(b) != int(essaimbp.BYTES_LENGTH_PATTERN) {
+		return fmt.Errorf("%w: got %d bytes, want %d", ErrBadSize, len(b), essaimbp.BYTES_LENGTH_PATTERN)
+	}
+
 	message := essaimbp.Pattern{}
 	message.Decode(b)
 
 	if message.Channel != 0 && message.Channel != ch {
-		return
+		return nil
 	}
 
-	p.stepsMu.RLock()
-	defer p.stepsMu.RUnlock()
+	return p.update(message)
+}
+
+// DecodeState updates the pattern from an encoded state message, with the
+// pattern played on the given channel, if any.
+func (p *ColorPattern) DecodeState(b []byte, ch uint64) error {
+	if len(b) != int(essaimbp.BYTES_LENGTH_STATE) {
+		return fmt.Errorf("%w: got %d bytes, want %d", ErrBadSize, len(b), essaimbp.BYTES_LENGTH_STATE)
+	}
+
+	message := essaimbp.State{}
+	message.Decode(b)
+
+	if ch >= uint64(len(message.Channels)) || message.Channels[ch].Pattern.Length == 0 {
+		return nil
+	}
+
+	return p.update(message.Channels[ch].Pattern)
+}
+
+func (p *ColorPattern) update(message essaimbp.Pattern) error {
+	if message.Length < MinSteps || int(message.Length) > MaxSteps {
+		return fmt.Errorf("%w: got %d", ErrBadLength, message.Length)
+	}
+
+	p.stepsMu.Lock()
+	defer p.stepsMu.Unlock()
+
+	if len(p.steps) != int(message.Length) {
+		p.steps = make([]color.RGBA, message.Length)
+	}
 
 	for idx := range p.steps {
 		p.steps[idx] = color.RGBA{
@@ -93,4 +177,6 @@ func (p *ColorPattern) Decode(b []byte, ch uint64) {
 			A: message.Steps[idx].A,
 		}
 	}
+
+	return nil
 }
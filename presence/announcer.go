@@ -0,0 +1,128 @@
+package presence
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	// HeartbeatInterval is the delay between two announces of a node.
+	HeartbeatInterval = time.Duration(time.Second)
+
+	// SilenceTimeout is the delay after which a node which did not announce
+	// itself is considered silent.
+	SilenceTimeout = 3 * HeartbeatInterval
+
+	// ForgetTimeout is the delay after which a silent node is forgotten, such
+	// as one restarted under a new id.
+	ForgetTimeout = 20 * SilenceTimeout
+)
+
+// Status is the part of an announce which changes over time.
+type Status struct {
+	Step   int64
+	Health essaimbp.NodeHealth
+}
+
+// Announcer periodically announces a node on the network, so that the
+// controller knows it is alive.
+type Announcer struct {
+	sender *protocol.Sender
+
+	role    essaimbp.NodeRole
+	channel uint64
+	started time.Time
+
+	statusFunc   func() Status
+	statusFuncMu sync.RWMutex
+}
+
+// NewAnnouncer returns an announcer writing to the given connection, one frame
+// per write.
+func NewAnnouncer(w io.Writer, role essaimbp.NodeRole, channel uint64, auth protocol.Auth) *Announcer {
+	return &Announcer{
+		sender:  protocol.NewSender(w, auth),
+		role:    role,
+		channel: channel,
+		started: time.Now(),
+	}
+}
+
+// ID returns the id under which the node is announced.
+func (a *Announcer) ID() uint32 {
+	return a.sender.ID()
+}
+
+// SetStatusFunc sets the function called before every announce to get the
+// current status of the node.
+func (a *Announcer) SetStatusFunc(f func() Status) {
+	a.statusFuncMu.Lock()
+	defer a.statusFuncMu.Unlock()
+
+	a.statusFunc = f
+}
+
+// Run announces the node every HeartbeatInterval until the context is done.
+// Failed announces are reported and retried on the next heartbeat.
+func (a *Announcer) Run(ctx context.Context) error {
+	t := time.NewTicker(HeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		if err := a.announce(); err != nil {
+			fmt.Printf("could not announce node: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// RequestState asks the controller for the complete state of the show, for
+// nodes joining late.
+func (a *Announcer) RequestState() error {
+	return a.sender.Send(essaimbp.MESSAGE_TYPE_STATE_REQUEST, nil)
+}
+
+func (a *Announcer) announce() error {
+	status := Status{
+		Health: essaimbp.NODE_HEALTH_OK,
+	}
+
+	a.statusFuncMu.RLock()
+	if a.statusFunc != nil {
+		status = a.statusFunc()
+	}
+	a.statusFuncMu.RUnlock()
+
+	message := essaimbp.Announce{
+		Node:    a.sender.ID(),
+		Role:    a.role,
+		Channel: a.channel,
+		Version: essaimbp.VERSION,
+		Uptime:  uint32(time.Since(a.started).Seconds()),
+		Step:    uint64(max(status.Step, 0)),
+		Health:  status.Health,
+	}
+
+	return a.sender.Send(essaimbp.MESSAGE_TYPE_ANNOUNCE, message.Encode())
+}
+
+// ReceptionHealth reports a node as degraded when frames were lost, could not
+// be decoded or failed authentication between two reports.
+func ReceptionHealth(previous, current protocol.Stats) essaimbp.NodeHealth {
+	if current.Lost > previous.Lost || current.Invalid > previous.Invalid || current.Rejected > previous.Rejected {
+		return essaimbp.NODE_HEALTH_DEGRADED
+	}
+
+	return essaimbp.NODE_HEALTH_OK
+}
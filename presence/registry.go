@@ -0,0 +1,180 @@
+package presence
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+var (
+	ErrBadSize = errors.New("announce message has an unexpected size")
+)
+
+// Node is the last known state of a node of the show.
+type Node struct {
+	ID       uint32
+	Role     essaimbp.NodeRole
+	Channel  uint64
+	Version  uint8
+	Uptime   time.Duration
+	Step     int64
+	Health   essaimbp.NodeHealth
+	Addr     netip.Addr
+	LastSeen time.Time
+
+	// Online is false once the node has been silent for SilenceTimeout.
+	Online bool
+	// Forgotten is true once the node has been silent for ForgetTimeout, and
+	// removed from the registry.
+	Forgotten bool
+}
+
+func (n Node) String() string {
+	role := strings.ToLower(strings.TrimPrefix(n.Role.String(), "NODE_ROLE_"))
+
+	return fmt.Sprintf("%s node %08x on channel %d (%s)", role, n.ID, n.Channel, n.Addr)
+}
+
+// Registry tracks the nodes announcing themselves on the network.
+type Registry struct {
+	nodes   map[uint32]*Node
+	nodesMu sync.RWMutex
+
+	onChange   func(node Node)
+	onChangeMu sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		nodes: make(map[uint32]*Node),
+	}
+}
+
+// SetOnChangeFunc sets the function called when a node appears, goes silent,
+// comes back, changes health or is forgotten.
+func (r *Registry) SetOnChangeFunc(f func(node Node)) {
+	r.onChangeMu.Lock()
+	defer r.onChangeMu.Unlock()
+
+	r.onChange = f
+}
+
+// Nodes returns every node known, sorted by role, channel and id.
+func (r *Registry) Nodes() []Node {
+	r.nodesMu.RLock()
+	nodes := make([]Node, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		nodes = append(nodes, *node)
+	}
+	r.nodesMu.RUnlock()
+
+	slices.SortFunc(nodes, func(a, b Node) int {
+		return cmp.Or(
+			cmp.Compare(a.Role, b.Role),
+			cmp.Compare(a.Channel, b.Channel),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+
+	return nodes
+}
+
+// Count returns the number of nodes online and the number of nodes known,
+// those forgotten excluded.
+func (r *Registry) Count() (online int, total int) {
+	r.nodesMu.RLock()
+	defer r.nodesMu.RUnlock()
+
+	for _, node := range r.nodes {
+		if node.Online {
+			online++
+		}
+	}
+
+	return online, len(r.nodes)
+}
+
+// Update records an announce received from the given address.
+func (r *Registry) Update(announce essaimbp.Announce, addr netip.Addr, now time.Time) {
+	r.nodesMu.Lock()
+	node, ok := r.nodes[announce.Node]
+	if !ok {
+		node = &Node{ID: announce.Node}
+		r.nodes[announce.Node] = node
+	}
+
+	changed := !node.Online || node.Health != announce.Health
+
+	node.Role = announce.Role
+	node.Channel = announce.Channel
+	node.Version = announce.Version
+	node.Uptime = time.Duration(announce.Uptime) * time.Second
+	node.Step = int64(announce.Step)
+	node.Health = announce.Health
+	node.Addr = addr
+	node.LastSeen = now
+	node.Online = true
+
+	updated := *node
+	r.nodesMu.Unlock()
+
+	if changed {
+		r.notify(updated)
+	}
+}
+
+// Expire marks the nodes which have been silent for too long as offline, and
+// forgets those silent for much longer.
+func (r *Registry) Expire(now time.Time) {
+	r.nodesMu.Lock()
+	expired := []Node{}
+	for id, node := range r.nodes {
+		silence := now.Sub(node.LastSeen)
+
+		switch {
+		case silence > ForgetTimeout:
+			delete(r.nodes, id)
+			node.Online = false
+			node.Forgotten = true
+			expired = append(expired, *node)
+
+		case node.Online && silence > SilenceTimeout:
+			node.Online = false
+			expired = append(expired, *node)
+		}
+	}
+	r.nodesMu.Unlock()
+
+	for _, node := range expired {
+		r.notify(node)
+	}
+}
+
+// DecodeAnnounce decodes the payload of an announce frame.
+func DecodeAnnounce(b []byte) (essaimbp.Announce, error) {
+	if len(b) != int(essaimbp.BYTES_LENGTH_ANNOUNCE) {
+		return essaimbp.Announce{}, fmt.Errorf("%w: got %d bytes", ErrBadSize, len(b))
+	}
+
+	announce := essaimbp.Announce{}
+	announce.Decode(b)
+
+	return announce, nil
+}
+
+func (r *Registry) notify(node Node) {
+	r.onChangeMu.RLock()
+	onChange := r.onChange
+	r.onChangeMu.RUnlock()
+
+	if onChange != nil {
+		onChange(node)
+	}
+}
@@ -0,0 +1,65 @@
+package presence
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+func expectCount(t *testing.T, r *Registry, online int, total int) {
+	t.Helper()
+
+	if gotOnline, gotTotal := r.Count(); gotOnline != online || gotTotal != total {
+		t.Fatalf("got %d nodes online out of %d, want %d out of %d", gotOnline, gotTotal, online, total)
+	}
+}
+
+func TestRegistryExpire(t *testing.T) {
+	r := NewRegistry()
+
+	var changes []Node
+	r.SetOnChangeFunc(func(node Node) {
+		changes = append(changes, node)
+	})
+
+	addr := netip.MustParseAddr("10.0.0.2")
+	start := time.Unix(1000, 0)
+	r.Update(essaimbp.Announce{Node: 1, Role: essaimbp.NODE_ROLE_DMX, Channel: 1}, addr, start)
+	r.Update(essaimbp.Announce{Node: 2, Role: essaimbp.NODE_ROLE_DMX, Channel: 2}, addr, start)
+	expectCount(t, r, 2, 2)
+
+	// The second node keeps announcing itself, the first one goes silent.
+	for at := start; !at.After(start.Add(ForgetTimeout + HeartbeatInterval)); at = at.Add(HeartbeatInterval) {
+		r.Update(essaimbp.Announce{Node: 2, Role: essaimbp.NODE_ROLE_DMX, Channel: 2}, addr, at)
+		r.Expire(at)
+
+		if silence := at.Sub(start); silence > SilenceTimeout && silence <= ForgetTimeout {
+			expectCount(t, r, 1, 2)
+		}
+	}
+	expectCount(t, r, 1, 1)
+
+	if nodes := r.Nodes(); len(nodes) != 1 || nodes[0].ID != 2 {
+		t.Fatalf("got nodes %v, want node 2 only", nodes)
+	}
+
+	// Both nodes appeared, then the first went silent and was forgotten.
+	if len(changes) != 4 {
+		t.Fatalf("got %d changes, want 4", len(changes))
+	}
+	if silent := changes[2]; silent.ID != 1 || silent.Online || silent.Forgotten {
+		t.Fatalf("got change %+v, want node 1 silent", silent)
+	}
+	if forgotten := changes[3]; forgotten.ID != 1 || forgotten.Online || !forgotten.Forgotten {
+		t.Fatalf("got change %+v, want node 1 forgotten", forgotten)
+	}
+
+	// A forgotten node announcing itself again appears as a new one.
+	r.Update(essaimbp.Announce{Node: 1, Role: essaimbp.NODE_ROLE_DMX, Channel: 1}, addr, start.Add(ForgetTimeout+time.Minute))
+	expectCount(t, r, 2, 2)
+	if node := changes[len(changes)-1]; node.ID != 1 || !node.Online || node.Forgotten {
+		t.Fatalf("got change %+v, want node 1 online", node)
+	}
+}
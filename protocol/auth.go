@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+const (
+	MACSize = sha256.Size
+)
+
+var (
+	ErrUnauthenticated = errors.New("frame is not authenticated")
+	ErrBadMAC          = errors.New("frame authentication failed")
+	ErrReplayed        = errors.New("frame timestamp is outside of the replay window")
+)
+
+// Auth configures the authentication of the frames with a key shared by every
+// node of the show. Frames are neither signed nor checked without a key.
+//
+// Replayed frames are rejected by following the sequence of every sender. The
+// timestamps of the frames are only checked with a window, as the nodes may
+// have no synchronized clock.
+type Auth struct {
+	Key []byte
+
+	// Window is the largest difference accepted between the timestamp of the
+	// first frame of a sender and the local time, which bounds how long a
+	// captured frame may be replayed once its sender has been forgotten. The
+	// timestamps are not checked if zero.
+	Window time.Duration
+}
+
+func (a Auth) Enabled() bool {
+	return len(a.Key) > 0
+}
+
+// sign appends the MAC of the given frame to it.
+func (a Auth) sign(frame []byte) []byte {
+	mac := hmac.New(sha256.New, a.Key)
+	mac.Write(frame)
+
+	return mac.Sum(frame)
+}
+
+// verify checks that the frame was signed with the key.
+func (a Auth) verify(frame Frame) error {
+	if frame.Header.Flags&essaimbp.FLAG_AUTHENTICATED == 0 {
+		return ErrUnauthenticated
+	}
+
+	mac := hmac.New(sha256.New, a.Key)
+	mac.Write(frame.signed)
+	if !hmac.Equal(mac.Sum(nil), frame.MAC) {
+		return ErrBadMAC
+	}
+
+	return nil
+}
+
+// checkTimestamp checks that a frame was sent within the window, if any.
+func (a Auth) checkTimestamp(header essaimbp.Header, now time.Time) error {
+	if !a.Enabled() || a.Window <= 0 {
+		return nil
+	}
+
+	sentAt := time.UnixMicro(int64(header.Timestamp))
+	if delta := now.Sub(sentAt); delta > a.Window || delta < -a.Window {
+		return fmt.Errorf("%w: sent %s ago", ErrReplayed, delta)
+	}
+
+	return nil
+}
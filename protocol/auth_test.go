@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+var testAuth = Auth{Key: []byte("essaim test key")}
+
+// signedFrame returns a signed frame of the given sender, sent at the given
+// time.
+func signedFrame(auth Auth, sender uint32, sequence uint32, sentAt time.Time) []byte {
+	header := essaimbp.Header{
+		Type:      essaimbp.MESSAGE_TYPE_STATE_REQUEST,
+		Flags:     essaimbp.FLAG_AUTHENTICATED,
+		Sequence:  sequence,
+		Sender:    sender,
+		Timestamp: uint64(sentAt.UnixMicro()),
+	}
+
+	return auth.sign(Encode(header, nil))
+}
+
+func expectRejected(t *testing.T, r *Receiver, frame []byte, want error) {
+	t.Helper()
+
+	if _, err := r.Receive(frame); !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+func TestAuthSigned(t *testing.T) {
+	w := &recorder{}
+	frames := sendFrames(t, NewSender(w, testAuth), w, 2)
+
+	r := NewReceiver(testAuth)
+	f := expectReceived(t, r, frames[0])
+	if f.Header.Flags&essaimbp.FLAG_AUTHENTICATED == 0 || len(f.MAC) != MACSize {
+		t.Fatalf("got flags %#x and a MAC of %d bytes, want a signed frame", f.Header.Flags, len(f.MAC))
+	}
+
+	// Receivers without a key accept signed frames.
+	expectReceived(t, NewReceiver(Auth{}), frames[1])
+}
+
+func TestAuthRejected(t *testing.T) {
+	w := &recorder{}
+	unsigned := sendFrames(t, NewSender(w, Auth{}), w, 1)[0]
+	signed := sendFrames(t, NewSender(w, testAuth), w, 1)[0]
+	forged := sendFrames(t, NewSender(w, Auth{Key: []byte("another key")}), w, 1)[0]
+
+	tampered := append([]byte(nil), signed...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	r := NewReceiver(testAuth)
+	expectRejected(t, r, unsigned, ErrUnauthenticated)
+	expectRejected(t, r, forged, ErrBadMAC)
+	expectRejected(t, r, tampered, ErrBadMAC)
+
+	if stats := r.Stats(); stats.Rejected != 3 || stats.Received != 0 {
+		t.Fatalf("got stats %+v, want 3 frames rejected", stats)
+	}
+	if senders := r.Senders(); len(senders) != 0 {
+		t.Fatalf("got senders %+v, want none", senders)
+	}
+}
+
+func TestAuthReplayed(t *testing.T) {
+	// Nodes without synchronized clocks accept each other's frames.
+	sentAt := time.Now().Add(-time.Hour)
+	r := NewReceiver(testAuth)
+	expectReceived(t, r, signedFrame(testAuth, 1, 10, sentAt))
+
+	// A captured frame replayed is older than the last one of its sender.
+	expectStale(t, r, signedFrame(testAuth, 1, 10, sentAt))
+	expectStale(t, r, signedFrame(testAuth, 1, 9, sentAt))
+}
+
+func TestAuthWindow(t *testing.T) {
+	auth := Auth{Key: testAuth.Key, Window: time.Second}
+	r := NewReceiver(auth)
+
+	now := time.Now()
+	expectRejected(t, r, signedFrame(auth, 1, 1, now.Add(-time.Minute)), ErrReplayed)
+	expectRejected(t, r, signedFrame(auth, 1, 1, now.Add(time.Minute)), ErrReplayed)
+
+	// Only the first frame of a sender is checked against the window, the
+	// next ones following its sequence.
+	expectReceived(t, r, signedFrame(auth, 1, 1, now))
+	expectReceived(t, r, signedFrame(auth, 1, 2, now.Add(-time.Minute)))
+
+	if stats := r.Stats(); stats.Rejected != 2 || stats.Received != 2 {
+		t.Fatalf("got stats %+v, want 2 frames rejected and 2 received", stats)
+	}
+}
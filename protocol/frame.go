@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+const (
+	HeaderSize = int(essaimbp.BYTES_LENGTH_HEADER)
+
+	// MaxFrameSize is the size of the largest frame a datagram may carry.
+	MaxFrameSize = 1500
+)
+
+var (
+	ErrTooShort           = errors.New("frame is shorter than a header")
+	ErrBadMagic           = errors.New("frame does not start with the essaim magic number")
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	ErrBadLength          = errors.New("frame length does not match its header")
+	ErrUnknownType        = errors.New("unknown message type")
+)
+
+// knownTypes lists the message types understood by this version. Frames of
+// other types are well-formed but skipped, so that new message types can be
+// introduced without breaking older clients.
+var knownTypes = map[essaimbp.MessageType]bool{
+	essaimbp.MESSAGE_TYPE_PATTERN:       true,
+	essaimbp.MESSAGE_TYPE_ANNOUNCE:      true,
+	essaimbp.MESSAGE_TYPE_STATE:         true,
+	essaimbp.MESSAGE_TYPE_STATE_REQUEST: true,
+	essaimbp.MESSAGE_TYPE_TAP:           true,
+}
+
+type Frame struct {
+	Header  essaimbp.Header
+	Payload []byte
+
+	// MAC authenticates the header and the payload of frames with the
+	// FLAG_AUTHENTICATED flag.
+	MAC []byte
+	// signed holds the bytes covered by the MAC.
+	signed []byte
+}
+
+// Encode frames the given message payload behind the given header, filling in
+// its magic number, version and length.
+func Encode(header essaimbp.Header, payload []byte) []byte {
+	header.Magic = essaimbp.MAGIC
+	header.Version = essaimbp.VERSION
+	header.Length = uint16(len(payload))
+
+	return append(header.Encode(), payload...)
+}
+
+// FrameSize returns the size of the frame starting with the given header,
+// including its MAC, so that frames can be read from a stream.
+func FrameSize(b []byte) (int, error) {
+	if len(b) < HeaderSize {
+		return 0, fmt.Errorf("%w: got %d bytes", ErrTooShort, len(b))
+	}
+
+	header := essaimbp.Header{}
+	header.Decode(b[:HeaderSize])
+
+	if header.Magic != essaimbp.MAGIC {
+		return 0, fmt.Errorf("%w: got %#04x", ErrBadMagic, header.Magic)
+	}
+
+	size := HeaderSize + int(header.Length)
+	if header.Flags&essaimbp.FLAG_AUTHENTICATED != 0 {
+		size += MACSize
+	}
+
+	return size, nil
+}
+
+// Decode checks the header of a frame and splits it from its payload. Frames
+// of an unknown message type are returned along with ErrUnknownType.
+func Decode(b []byte) (Frame, error) {
+	if len(b) < HeaderSize {
+		return Frame{}, fmt.Errorf("%w: got %d bytes", ErrTooShort, len(b))
+	}
+
+	header := essaimbp.Header{}
+	header.Decode(b[:HeaderSize])
+
+	if header.Magic != essaimbp.MAGIC {
+		return Frame{}, fmt.Errorf("%w: got %#04x", ErrBadMagic, header.Magic)
+	}
+
+	if header.Version != essaimbp.VERSION {
+		return Frame{}, fmt.Errorf("%w: got %d, want %d", ErrUnsupportedVersion, header.Version, essaimbp.VERSION)
+	}
+
+	trailerSize := 0
+	if header.Flags&essaimbp.FLAG_AUTHENTICATED != 0 {
+		trailerSize = MACSize
+	}
+
+	if int(header.Length) != len(b)-HeaderSize-trailerSize {
+		return Frame{}, fmt.Errorf("%w: header announces %d bytes, got %d", ErrBadLength, header.Length, len(b)-HeaderSize-trailerSize)
+	}
+
+	end := len(b) - trailerSize
+	frame := Frame{
+		Header:  header,
+		Payload: b[HeaderSize:end],
+		signed:  b[:end],
+	}
+	if trailerSize > 0 {
+		frame.MAC = b[end:]
+	}
+
+	if !knownTypes[header.Type] {
+		return frame, fmt.Errorf("%w: %s", ErrUnknownType, header.Type)
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+const (
+	// senderTimeout is the delay after which a silent sender is forgotten.
+	senderTimeout = time.Duration(time.Minute)
+)
+
+var (
+	ErrStale = errors.New("frame is not newer than the last one of its sender")
+)
+
+// Stats counts the frames seen by a Receiver.
+type Stats struct {
+	// Received is the number of frames accepted.
+	Received uint64
+	// Lost is the number of frames missing from the sequences of the senders
+	// when a newer frame was received. Those arriving later on are also
+	// counted as out of order.
+	Lost uint64
+	// OutOfOrder is the number of frames dropped because a newer frame of
+	// their sender had already been received.
+	OutOfOrder uint64
+	// Duplicated is the number of frames dropped because they were already
+	// received.
+	Duplicated uint64
+	// Invalid is the number of frames which could not be decoded.
+	Invalid uint64
+	// Rejected is the number of frames which failed authentication.
+	Rejected uint64
+}
+
+type SenderStats struct {
+	ID        uint32
+	Sequence  uint32
+	Timestamp time.Time
+	LastSeen  time.Time
+}
+
+// Receiver decodes frames and follows the sequence of each of their senders,
+// so that delayed frames never override newer ones. When authentication is
+// enabled, frames which are not signed with the key are rejected.
+type Receiver struct {
+	auth Auth
+
+	senders map[uint32]*SenderStats
+	stats   Stats
+	mu      sync.Mutex
+}
+
+func NewReceiver(auth Auth) *Receiver {
+	return &Receiver{
+		auth:    auth,
+		senders: make(map[uint32]*SenderStats),
+	}
+}
+
+// Receive decodes a frame and checks it against the last frame of its sender.
+// Frames which are not newer are returned along with ErrStale and should be
+// dropped, as well as frames failing authentication. Frames of an unknown
+// message type are returned along with ErrUnknownType, as with Decode.
+func (r *Receiver) Receive(b []byte) (Frame, error) {
+	frame, err := Decode(b)
+	if err != nil && !errors.Is(err, ErrUnknownType) {
+		r.mu.Lock()
+		r.stats.Invalid++
+		r.mu.Unlock()
+
+		return Frame{}, err
+	}
+
+	if r.auth.Enabled() {
+		if err := r.auth.verify(frame); err != nil {
+			r.mu.Lock()
+			r.stats.Rejected++
+			r.mu.Unlock()
+
+			return Frame{}, err
+		}
+	}
+
+	// Senders are only tracked once authenticated, so that forged frames
+	// cannot push their sequence forward.
+	if err := r.track(frame.Header); err != nil {
+		return Frame{}, err
+	}
+
+	return frame, err
+}
+
+func (r *Receiver) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stats
+}
+
+// Senders returns the senders heard from recently.
+func (r *Receiver) Senders() []SenderStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	senders := make([]SenderStats, 0, len(r.senders))
+	for _, sender := range r.senders {
+		senders = append(senders, *sender)
+	}
+
+	return senders
+}
+
+func (r *Receiver) track(header essaimbp.Header) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	timestamp := time.UnixMicro(int64(header.Timestamp))
+
+	sender, ok := r.senders[header.Sender]
+	if !ok || now.Sub(sender.LastSeen) > senderTimeout {
+		// The sequence of an unknown sender cannot tell whether its frame is
+		// replayed, unlike its timestamp.
+		if err := r.auth.checkTimestamp(header, now); err != nil {
+			r.stats.Rejected++
+			return err
+		}
+
+		r.forgetSenders(now)
+		r.senders[header.Sender] = &SenderStats{
+			ID:        header.Sender,
+			Sequence:  header.Sequence,
+			Timestamp: timestamp,
+			LastSeen:  now,
+		}
+		r.stats.Received++
+
+		return nil
+	}
+
+	// The difference is taken as signed so that sequences keep working once
+	// they wrap around.
+	delta := int32(header.Sequence - sender.Sequence)
+	switch {
+	case delta == 0:
+		r.stats.Duplicated++
+		return fmt.Errorf("%w: sequence %d received twice", ErrStale, header.Sequence)
+	case delta < 0:
+		r.stats.OutOfOrder++
+		return fmt.Errorf("%w: sequence %d received after %d", ErrStale, header.Sequence, sender.Sequence)
+	}
+
+	r.stats.Lost += uint64(delta - 1)
+	r.stats.Received++
+
+	sender.Sequence = header.Sequence
+	sender.Timestamp = timestamp
+	sender.LastSeen = now
+
+	return nil
+}
+
+// forgetSenders must be called with the lock held.
+func (r *Receiver) forgetSenders(now time.Time) {
+	for id, sender := range r.senders {
+		if now.Sub(sender.LastSeen) > senderTimeout {
+			delete(r.senders, id)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package protocol
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+// recorder keeps every frame written to it.
+type recorder struct {
+	frames [][]byte
+	mu     sync.Mutex
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	// Let concurrent writers race for the lock, as they would for a socket.
+	runtime.Gosched()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.frames = append(r.frames, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+// sendFrames returns the given number of frames sent by a sender.
+func sendFrames(t *testing.T, s *Sender, r *recorder, n int) [][]byte {
+	t.Helper()
+
+	r.frames = nil
+	for range n {
+		if err := s.Send(essaimbp.MESSAGE_TYPE_STATE_REQUEST, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return r.frames
+}
+
+func expectReceived(t *testing.T, r *Receiver, frame []byte) Frame {
+	t.Helper()
+
+	f, err := r.Receive(frame)
+	if err != nil {
+		t.Fatalf("got error %v, want none", err)
+	}
+
+	return f
+}
+
+func expectStale(t *testing.T, r *Receiver, frame []byte) {
+	t.Helper()
+
+	if _, err := r.Receive(frame); !errors.Is(err, ErrStale) {
+		t.Fatalf("got error %v, want %v", err, ErrStale)
+	}
+}
+
+func TestReceiverSequences(t *testing.T) {
+	w := &recorder{}
+	s := NewSender(w, Auth{})
+	frames := sendFrames(t, s, w, 5)
+
+	r := NewReceiver(Auth{})
+
+	f := expectReceived(t, r, frames[0])
+	if f.Header.Sender != s.ID() || f.Header.Sequence != 1 {
+		t.Fatalf("got sequence %d of sender %d, want sequence 1 of sender %d", f.Header.Sequence, f.Header.Sender, s.ID())
+	}
+
+	// The second frame is lost, or only delayed.
+	expectReceived(t, r, frames[2])
+	expectStale(t, r, frames[1])
+	expectStale(t, r, frames[2])
+	expectReceived(t, r, frames[4])
+
+	want := Stats{Received: 3, Lost: 2, OutOfOrder: 1, Duplicated: 1}
+	if stats := r.Stats(); stats != want {
+		t.Fatalf("got stats %+v, want %+v", stats, want)
+	}
+
+	senders := r.Senders()
+	if len(senders) != 1 || senders[0].ID != s.ID() || senders[0].Sequence != 5 {
+		t.Fatalf("got senders %+v, want sender %d at sequence 5", senders, s.ID())
+	}
+}
+
+func TestReceiverWrapAround(t *testing.T) {
+	w := &recorder{}
+	s := NewSender(w, Auth{})
+	s.sequence = math.MaxUint32 - 1
+	frames := sendFrames(t, s, w, 3)
+
+	r := NewReceiver(Auth{})
+	for idx, sequence := range []uint32{math.MaxUint32, 0, 1} {
+		if f := expectReceived(t, r, frames[idx]); f.Header.Sequence != sequence {
+			t.Fatalf("got sequence %d, want %d", f.Header.Sequence, sequence)
+		}
+	}
+
+	// The frame sent before wrapping around is older than the last one.
+	expectStale(t, r, frames[0])
+}
+
+func TestReceiverSenders(t *testing.T) {
+	w := &recorder{}
+	first := sendFrames(t, NewSender(w, Auth{}), w, 2)
+	second := sendFrames(t, NewSender(w, Auth{}), w, 2)
+
+	// The sequences of the senders are followed independently.
+	r := NewReceiver(Auth{})
+	expectReceived(t, r, first[1])
+	expectReceived(t, r, second[0])
+	expectReceived(t, r, second[1])
+	expectStale(t, r, first[0])
+
+	if senders := r.Senders(); len(senders) != 2 {
+		t.Fatalf("got %d senders, want 2", len(senders))
+	}
+}
+
+func TestReceiverInvalid(t *testing.T) {
+	r := NewReceiver(Auth{})
+
+	if _, err := r.Receive([]byte("not a frame")); !errors.Is(err, ErrTooShort) && !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("got error %v, want %v or %v", err, ErrTooShort, ErrBadMagic)
+	}
+
+	if stats := r.Stats(); stats.Invalid != 1 || stats.Received != 0 {
+		t.Fatalf("got stats %+v, want a single invalid frame", stats)
+	}
+}
+
+func TestSenderConcurrent(t *testing.T) {
+	w := &recorder{}
+	s := NewSender(w, Auth{})
+
+	// Frames sent at the same time are written in the order of their
+	// sequence, so that none is dropped as stale.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				s.Send(essaimbp.MESSAGE_TYPE_STATE_REQUEST, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	r := NewReceiver(Auth{})
+	for _, frame := range w.frames {
+		expectReceived(t, r, frame)
+	}
+
+	if stats := r.Stats(); stats.Received != 800 || stats.Lost != 0 {
+		t.Fatalf("got stats %+v, want 800 frames received", stats)
+	}
+}
@@ -0,0 +1,66 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+)
+
+// Sender frames messages and numbers them before writing them, one frame per
+// write. Each sender picks a random id, so that receivers can follow the
+// sequence of every sender independently. Frames are signed when
+// authentication is enabled.
+type Sender struct {
+	w    io.Writer
+	auth Auth
+
+	id uint32
+
+	// sendMu serializes the frames, so that they are written in the order of
+	// their sequence.
+	sequence uint32
+	sendMu   sync.Mutex
+}
+
+func NewSender(w io.Writer, auth Auth) *Sender {
+	return &Sender{
+		w:    w,
+		auth: auth,
+		id:   rand.Uint32(),
+	}
+}
+
+func (s *Sender) ID() uint32 {
+	return s.id
+}
+
+func (s *Sender) Send(messageType essaimbp.MessageType, payload []byte) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	s.sequence++
+	header := essaimbp.Header{
+		Type:      messageType,
+		Sequence:  s.sequence,
+		Sender:    s.id,
+		Timestamp: uint64(time.Now().UnixMicro()),
+	}
+	if s.auth.Enabled() {
+		header.Flags |= essaimbp.FLAG_AUTHENTICATED
+	}
+
+	frame := Encode(header, payload)
+	if s.auth.Enabled() {
+		frame = s.auth.sign(frame)
+	}
+
+	if _, err := s.w.Write(frame); err != nil {
+		return fmt.Errorf("could not write frame: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package transport
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func setMulticastOptions(conn *net.UDPConn, is6 bool, cfg UDPConfig) error {
+	var errs []error
+
+	if is6 {
+		p := ipv6.NewPacketConn(conn)
+		if cfg.TTL > 0 {
+			errs = append(errs, p.SetMulticastHopLimit(cfg.TTL))
+		}
+		errs = append(errs, p.SetMulticastLoopback(!cfg.DisableLoopback))
+		if cfg.Interface != nil {
+			errs = append(errs, p.SetMulticastInterface(cfg.Interface))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	p := ipv4.NewPacketConn(conn)
+	if cfg.TTL > 0 {
+		errs = append(errs, p.SetMulticastTTL(cfg.TTL))
+	}
+	errs = append(errs, p.SetMulticastLoopback(!cfg.DisableLoopback))
+	if cfg.Interface != nil {
+		errs = append(errs, p.SetMulticastInterface(cfg.Interface))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,417 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"essaim.dev/essaim/protocol"
+)
+
+const (
+	dialTimeout  = time.Duration(time.Second * 2)
+	writeTimeout = time.Duration(time.Second)
+
+	// minBackoff and maxBackoff bound the delay between two connection
+	// attempts, which doubles after every failure.
+	minBackoff = time.Duration(time.Millisecond * 100)
+	maxBackoff = time.Duration(time.Second * 5)
+
+	incomingBufferSize = 16
+	// peerQueueSize is the number of frames waiting to be sent to a node
+	// before it is considered stalled and disconnected.
+	peerQueueSize = 64
+)
+
+type packet struct {
+	b    []byte
+	from netip.AddrPort
+}
+
+// TCPServer is the TCP transport of the controller: every frame written is
+// sent to each connected node.
+type TCPServer struct {
+	listener *net.TCPListener
+
+	peers   map[*net.TCPConn]*peer
+	peersMu sync.RWMutex
+
+	incoming chan packet
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func ListenTCP(addr netip.AddrPort) (*TCPServer, error) {
+	listener, err := net.ListenTCP("tcp", net.TCPAddrFromAddrPort(addr))
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on tcp address: %w", err)
+	}
+
+	s := &TCPServer{
+		listener: listener,
+		peers:    make(map[*net.TCPConn]*peer),
+		incoming: make(chan packet, incomingBufferSize),
+		done:     make(chan struct{}),
+	}
+	go s.accept()
+
+	return s, nil
+}
+
+func (s *TCPServer) ReadFrom(b []byte) (int, netip.AddrPort, error) {
+	select {
+	case p := <-s.incoming:
+		return copy(b, p.b), p.from, nil
+	case <-s.done:
+		return 0, netip.AddrPort{}, net.ErrClosed
+	}
+}
+
+// Write queues the frame for every node, each being sent its frames by its own
+// goroutine. The nodes which cannot keep up are disconnected, and expected to
+// reconnect.
+func (s *TCPServer) Write(b []byte) (int, error) {
+	frame := append([]byte(nil), b...)
+
+	s.peersMu.RLock()
+	peers := make([]*peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.peersMu.RUnlock()
+
+	for _, p := range peers {
+		select {
+		case p.queue <- frame:
+		default:
+			fmt.Printf("dropping node %s: too many frames waiting\n", p.conn.RemoteAddr())
+			s.drop(p.conn)
+		}
+	}
+
+	return len(b), nil
+}
+
+func (s *TCPServer) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	s.peersMu.RLock()
+	peers := make([]*net.TCPConn, 0, len(s.peers))
+	for conn := range s.peers {
+		peers = append(peers, conn)
+	}
+	s.peersMu.RUnlock()
+
+	for _, conn := range peers {
+		s.drop(conn)
+	}
+
+	return s.listener.Close()
+}
+
+func (s *TCPServer) accept() {
+	for {
+		conn, err := s.listener.AcceptTCP()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("could not accept node: %s\n", err)
+			continue
+		}
+
+		p := &peer{
+			conn:  conn,
+			queue: make(chan []byte, peerQueueSize),
+			done:  make(chan struct{}),
+		}
+
+		s.peersMu.Lock()
+		s.peers[conn] = p
+		s.peersMu.Unlock()
+
+		go s.serve(conn)
+		go s.send(p)
+	}
+}
+
+func (s *TCPServer) serve(conn *net.TCPConn) {
+	defer s.drop(conn)
+
+	from := conn.RemoteAddr().(*net.TCPAddr).AddrPort()
+	from = netip.AddrPortFrom(from.Addr().Unmap(), from.Port())
+
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		n, err := readFrame(conn, b)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("node %s disconnected: %s\n", from, err)
+			}
+			return
+		}
+
+		select {
+		case s.incoming <- packet{b: append([]byte(nil), b[:n]...), from: from}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// send writes the frames queued for a node until it is dropped.
+func (s *TCPServer) send(p *peer) {
+	for {
+		select {
+		case frame := <-p.queue:
+			p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := p.conn.Write(frame); err != nil {
+				fmt.Printf("dropping node %s: %s\n", p.conn.RemoteAddr(), err)
+				s.drop(p.conn)
+				return
+			}
+
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (s *TCPServer) drop(conn *net.TCPConn) {
+	s.peersMu.Lock()
+	p, ok := s.peers[conn]
+	delete(s.peers, conn)
+	s.peersMu.Unlock()
+
+	if ok {
+		close(p.done)
+	}
+	conn.Close()
+}
+
+// peer is a node connected to the server, with the frames waiting to be sent
+// to it.
+type peer struct {
+	conn  *net.TCPConn
+	queue chan []byte
+	done  chan struct{}
+}
+
+// TCPClient is the TCP transport of a node. It keeps connecting to the
+// controller, backing off between failed attempts.
+type TCPClient struct {
+	addr netip.AddrPort
+
+	conn   *net.TCPConn
+	connMu sync.RWMutex
+
+	onConnect   func()
+	onConnectMu sync.RWMutex
+
+	incoming chan packet
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func DialTCP(addr netip.AddrPort) *TCPClient {
+	c := &TCPClient{
+		addr:     addr,
+		incoming: make(chan packet, incomingBufferSize),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+
+	return c
+}
+
+// SetOnConnectFunc sets the function called every time the connection to the
+// controller is established, or right away if it already is.
+func (c *TCPClient) SetOnConnectFunc(f func()) {
+	c.onConnectMu.Lock()
+	c.onConnect = f
+	c.onConnectMu.Unlock()
+
+	c.connMu.RLock()
+	connected := c.conn != nil
+	c.connMu.RUnlock()
+
+	if connected && f != nil {
+		go f()
+	}
+}
+
+func (c *TCPClient) ReadFrom(b []byte) (int, netip.AddrPort, error) {
+	select {
+	case p := <-c.incoming:
+		return copy(b, p.b), p.from, nil
+	case <-c.done:
+		return 0, netip.AddrPort{}, net.ErrClosed
+	}
+}
+
+// Write sends the frame to the controller, or fails with ErrNotConnected while
+// the connection is being reestablished.
+func (c *TCPClient) Write(b []byte) (int, error) {
+	c.connMu.RLock()
+	conn := c.conn
+	c.connMu.RUnlock()
+
+	if conn == nil {
+		return 0, ErrNotConnected
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	n, err := conn.Write(b)
+	if err != nil {
+		// The reading side notices the closed connection and reconnects.
+		conn.Close()
+	}
+
+	return n, err
+}
+
+func (c *TCPClient) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+
+	return nil
+}
+
+func (c *TCPClient) run() {
+	var backoff backoff
+
+	for {
+		dialer := net.Dialer{Timeout: dialTimeout}
+		conn, err := dialer.Dial("tcp", c.addr.String())
+		if err != nil {
+			delay := backoff.fail()
+			fmt.Printf("could not connect to controller: %s, retrying in %s\n", err, delay)
+
+			select {
+			case <-time.After(delay):
+			case <-c.done:
+				return
+			}
+			continue
+		}
+
+		connectedAt := time.Now()
+		c.serve(conn.(*net.TCPConn))
+		backoff.connected(time.Since(connectedAt))
+
+		select {
+		case <-time.After(backoff.fail()):
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// backoff is the delay between two connection attempts, which doubles after
+// every failure.
+type backoff struct {
+	delay time.Duration
+}
+
+// fail returns the delay before the next attempt, doubling the following one.
+func (b *backoff) fail() time.Duration {
+	delay := max(b.delay, minBackoff)
+	b.delay = min(delay*2, maxBackoff)
+
+	return delay
+}
+
+// connected resets the delay after a connection which lasted. A controller
+// dropping the connections right away is backed off from as a failing one.
+func (b *backoff) connected(lasted time.Duration) {
+	if lasted > maxBackoff {
+		b.delay = minBackoff
+	}
+}
+
+// serve reads frames from the connection until it breaks.
+func (c *TCPClient) serve(conn *net.TCPConn) {
+	c.connMu.Lock()
+	c.conn = conn
+	c.connMu.Unlock()
+
+	defer func() {
+		c.connMu.Lock()
+		c.conn = nil
+		c.connMu.Unlock()
+
+		conn.Close()
+	}()
+
+	// The connection may have been closed while dialing.
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	c.onConnectMu.RLock()
+	onConnect := c.onConnect
+	c.onConnectMu.RUnlock()
+
+	if onConnect != nil {
+		onConnect()
+	}
+
+	from := conn.RemoteAddr().(*net.TCPAddr).AddrPort()
+
+	b := make([]byte, protocol.MaxFrameSize)
+	for {
+		n, err := readFrame(conn, b)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("lost connection to controller: %s\n", err)
+			}
+			return
+		}
+
+		select {
+		case c.incoming <- packet{b: append([]byte(nil), b[:n]...), from: from}:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readFrame reads a single frame from a stream, using the length announced by
+// its header.
+func readFrame(r io.Reader, b []byte) (int, error) {
+	if _, err := io.ReadFull(r, b[:protocol.HeaderSize]); err != nil {
+		return 0, err
+	}
+
+	size, err := protocol.FrameSize(b[:protocol.HeaderSize])
+	if err != nil {
+		return 0, fmt.Errorf("could not read frame: %w", err)
+	}
+	if size > len(b) {
+		return 0, fmt.Errorf("could not read frame: %d bytes is too large", size)
+	}
+
+	if _, err := io.ReadFull(r, b[protocol.HeaderSize:size]); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
@@ -0,0 +1,322 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"essaim.dev/essaim/api/essaimbp"
+	"essaim.dev/essaim/protocol"
+)
+
+func testFrame(payload string) []byte {
+	return protocol.Encode(essaimbp.Header{Type: essaimbp.MESSAGE_TYPE_PATTERN}, []byte(payload))
+}
+
+func listenTestTCP(t *testing.T, addr netip.AddrPort) *TCPServer {
+	t.Helper()
+
+	s, err := ListenTCP(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func serverAddr(s *TCPServer) netip.AddrPort {
+	return s.listener.Addr().(*net.TCPAddr).AddrPort()
+}
+
+func peerCount(s *TCPServer) int {
+	s.peersMu.RLock()
+	defer s.peersMu.RUnlock()
+
+	return len(s.peers)
+}
+
+func waitPeers(t *testing.T, s *TCPServer, n int) {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for peerCount(s) != n {
+		select {
+		case <-time.After(time.Millisecond):
+		case <-timeout:
+			t.Fatalf("got %d nodes connected, want %d", peerCount(s), n)
+		}
+	}
+}
+
+// expectFrame reads the next frame of a connection, which must be the given
+// one.
+func expectFrame(t *testing.T, conn Conn, want []byte) netip.AddrPort {
+	t.Helper()
+
+	type result struct {
+		b    []byte
+		from netip.AddrPort
+		err  error
+	}
+	read := make(chan result, 1)
+	go func() {
+		b := make([]byte, protocol.MaxFrameSize)
+		n, from, err := conn.ReadFrom(b)
+		read <- result{b[:n], from, err}
+	}()
+
+	select {
+	case r := <-read:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if !bytes.Equal(r.b, want) {
+			t.Fatalf("got frame % x, want % x", r.b, want)
+		}
+		return r.from
+
+	case <-time.After(2 * time.Second):
+		t.Fatalf("got no frame, want % x", want)
+		return netip.AddrPort{}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	frames := [][]byte{testFrame("first"), testFrame(""), testFrame("third")}
+
+	// Frames are read whole, however the stream splits them.
+	r := iotest.OneByteReader(bytes.NewReader(bytes.Join(frames, nil)))
+	b := make([]byte, protocol.MaxFrameSize)
+	for _, want := range frames {
+		n, err := readFrame(r, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b[:n], want) {
+			t.Fatalf("got frame % x, want % x", b[:n], want)
+		}
+	}
+
+	if _, err := readFrame(bytes.NewReader(make([]byte, protocol.HeaderSize)), b); !errors.Is(err, protocol.ErrBadMagic) {
+		t.Fatalf("got error %v, want %v", err, protocol.ErrBadMagic)
+	}
+
+	// A frame larger than the buffer is not read.
+	large := testFrame(string(make([]byte, protocol.MaxFrameSize)))
+	if _, err := readFrame(bytes.NewReader(large), b); err == nil {
+		t.Fatal("got no error for a frame larger than the buffer")
+	}
+}
+
+func TestTCPServerSplitFrames(t *testing.T) {
+	s := listenTestTCP(t, netip.MustParseAddrPort("127.0.0.1:0"))
+
+	conn, err := net.DialTCP("tcp", nil, net.TCPAddrFromAddrPort(serverAddr(s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetNoDelay(true)
+
+	// The frames are written in pieces cutting through their headers and
+	// payloads.
+	first, second := testFrame("first frame"), testFrame("second frame")
+	stream := append(append([]byte(nil), first...), second...)
+	for _, cut := range [][2]int{{0, 3}, {3, len(first) - 2}, {len(first) - 2, len(first) + 5}, {len(first) + 5, len(stream)}} {
+		if _, err := conn.Write(stream[cut[0]:cut[1]]); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	from := expectFrame(t, s, first)
+	if want := conn.LocalAddr().(*net.TCPAddr).AddrPort(); from != want {
+		t.Fatalf("got frame from %s, want %s", from, want)
+	}
+	expectFrame(t, s, second)
+}
+
+func TestTCPClientReconnect(t *testing.T) {
+	s := listenTestTCP(t, netip.MustParseAddrPort("127.0.0.1:0"))
+	addr := serverAddr(s)
+
+	c := DialTCP(addr)
+	defer c.Close()
+
+	connects := make(chan struct{}, 4)
+	c.SetOnConnectFunc(func() {
+		connects <- struct{}{}
+	})
+
+	exchange := func(server string) {
+		t.Helper()
+
+		select {
+		case <-connects:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got no connection to the %s server", server)
+		}
+		waitPeers(t, s, 1)
+
+		frame := testFrame(server)
+		if _, err := s.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+		expectFrame(t, c, frame)
+
+		if _, err := c.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+		expectFrame(t, s, frame)
+	}
+
+	exchange("first")
+
+	// The client reconnects once the server is back on the same address.
+	s.Close()
+	s = listenTestTCP(t, addr)
+
+	exchange("restarted")
+}
+
+func TestTCPClientNotConnected(t *testing.T) {
+	// Nothing listens on the port of a closed listener.
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().(*net.TCPAddr).AddrPort()
+	listener.Close()
+
+	c := DialTCP(addr)
+	if _, err := c.Write(testFrame("lost")); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("got error %v, want %v", err, ErrNotConnected)
+	}
+
+	c.Close()
+	if _, _, err := c.ReadFrom(make([]byte, protocol.MaxFrameSize)); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("got error %v, want %v", err, net.ErrClosed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var b backoff
+
+	// The delay doubles after every failure, up to maxBackoff.
+	want := minBackoff
+	for range 10 {
+		if delay := b.fail(); delay != want {
+			t.Fatalf("got delay %s, want %s", delay, want)
+		}
+		want = min(want*2, maxBackoff)
+	}
+
+	// A connection dropped right away does not reset the delay.
+	b.connected(time.Second)
+	if delay := b.fail(); delay != maxBackoff {
+		t.Fatalf("got delay %s after a short connection, want %s", delay, maxBackoff)
+	}
+
+	b.connected(maxBackoff + time.Second)
+	if delay := b.fail(); delay != minBackoff {
+		t.Fatalf("got delay %s after a long connection, want %s", delay, minBackoff)
+	}
+	if delay := b.fail(); delay != 2*minBackoff {
+		t.Fatalf("got delay %s, want %s", delay, 2*minBackoff)
+	}
+}
+
+func TestTCPServerSlowPeer(t *testing.T) {
+	s := listenTestTCP(t, netip.MustParseAddrPort("127.0.0.1:0"))
+
+	// The slow node never reads its frames.
+	slow, err := net.DialTCP("tcp", nil, net.TCPAddrFromAddrPort(serverAddr(s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	slow.SetReadBuffer(1024)
+
+	c := DialTCP(serverAddr(s))
+	defer c.Close()
+	waitPeers(t, s, 2)
+
+	// The buffers of the slow node are kept small for its queue to fill up
+	// quickly.
+	s.peersMu.RLock()
+	for conn := range s.peers {
+		if conn.RemoteAddr().String() == slow.LocalAddr().String() {
+			conn.SetWriteBuffer(1024)
+		}
+	}
+	s.peersMu.RUnlock()
+
+	// The fast node counts the frames it receives up to the last one.
+	last := testFrame("last")
+	received := make(chan int, 1)
+	go func() {
+		count := 0
+		b := make([]byte, protocol.MaxFrameSize)
+		for {
+			n, _, err := c.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			count++
+
+			if bytes.Equal(b[:n], last) {
+				received <- count
+				return
+			}
+		}
+	}()
+
+	// Writing never waits for the nodes, the slow one being dropped once too
+	// many frames are waiting for it. The frames are paced for the fast node
+	// to keep up.
+	frame := testFrame(string(make([]byte, 1000)))
+	sent := 0
+	timeout := time.After(5 * time.Second)
+	for peerCount(s) == 2 {
+		start := time.Now()
+		if _, err := s.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Fatalf("got a write blocking for %s", elapsed)
+		}
+		sent++
+
+		select {
+		case <-time.After(time.Millisecond):
+		case <-timeout:
+			t.Fatalf("got the slow node still connected after %d frames", sent)
+		}
+	}
+
+	s.peersMu.RLock()
+	for conn := range s.peers {
+		if conn.RemoteAddr().String() == slow.LocalAddr().String() {
+			t.Fatal("got the fast node dropped instead of the slow one")
+		}
+	}
+	s.peersMu.RUnlock()
+
+	// The other node keeps receiving every frame.
+	s.Write(last)
+	sent++
+
+	select {
+	case count := <-received:
+		if count != sent {
+			t.Fatalf("got %d frames on the fast node, want %d", count, sent)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("got no last frame on the fast node")
+	}
+}
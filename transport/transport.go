@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+const (
+	UDP = "udp"
+	TCP = "tcp"
+)
+
+var (
+	ErrNotConnected = errors.New("not connected")
+)
+
+// Conn carries essaim frames between the controller and the nodes, one frame
+// per read or write.
+type Conn interface {
+	// ReadFrom reads a single frame and returns the address of its peer.
+	ReadFrom(b []byte) (int, netip.AddrPort, error)
+	// Write sends a single frame to the peers.
+	Write(b []byte) (int, error)
+	Close() error
+}
+
+// Connector is implemented by the connections which may be lost and
+// reestablished.
+type Connector interface {
+	// SetOnConnectFunc sets the function called every time the connection is
+	// established.
+	SetOnConnectFunc(f func())
+}
+
+// Listen opens the transport of the controller, which sends frames to every
+// node. With TCP, the controller listens on the given address for the nodes.
+func Listen(kind string, addr netip.AddrPort, cfg UDPConfig) (Conn, error) {
+	switch kind {
+	case UDP:
+		return OpenUDP(addr, cfg)
+	case TCP:
+		return ListenTCP(addr)
+	default:
+		return nil, fmt.Errorf("unknown transport: %q", kind)
+	}
+}
+
+// Dial opens the transport of a node. With TCP, the node connects to the
+// controller at the given address.
+func Dial(kind string, addr netip.AddrPort, cfg UDPConfig) (Conn, error) {
+	switch kind {
+	case UDP:
+		return OpenUDP(addr, cfg)
+	case TCP:
+		return DialTCP(addr), nil
+	default:
+		return nil, fmt.Errorf("unknown transport: %q", kind)
+	}
+}
@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// readBufferSize is the size of the kernel buffer of the listening sockets,
+// large enough to hold the bursts of frames of every node on the group.
+const readBufferSize = 1 << 20
+
+// UDPConfig configures the sockets exchanging datagrams. Its zero value keeps
+// the defaults of the system.
+type UDPConfig struct {
+	// Interface is the interface on which multicast groups are joined and
+	// datagrams sent, or the one chosen by the system if nil.
+	Interface *net.Interface
+	// TTL is the hop limit of the multicast datagrams sent, which do not go
+	// past the first router with the default of 1.
+	TTL int
+	// DisableLoopback stops the multicast datagrams sent from being received
+	// on the same host.
+	DisableLoopback bool
+}
+
+// UDPConn exchanges frames as datagrams sent to an address, usually a
+// multicast group joined by the controller and every node.
+type UDPConn struct {
+	listen *net.UDPConn
+	dial   *net.UDPConn
+}
+
+func OpenUDP(addr netip.AddrPort, cfg UDPConfig) (*UDPConn, error) {
+	var listen *net.UDPConn
+	var err error
+
+	if addr.Addr().IsMulticast() {
+		listen, err = net.ListenMulticastUDP(Network(addr), cfg.Interface, net.UDPAddrFromAddrPort(addr))
+	} else {
+		local := netip.AddrPortFrom(netip.IPv4Unspecified(), addr.Port())
+		if addr.Addr().Is6() {
+			local = netip.AddrPortFrom(netip.IPv6Unspecified(), addr.Port())
+		}
+		listen, err = net.ListenUDP(Network(addr), net.UDPAddrFromAddrPort(local))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on udp address: %w", err)
+	}
+	listen.SetReadBuffer(readBufferSize)
+
+	dial, err := DialUDP(addr, cfg)
+	if err != nil {
+		listen.Close()
+		return nil, err
+	}
+
+	return &UDPConn{
+		listen: listen,
+		dial:   dial,
+	}, nil
+}
+
+// DialUDP returns a socket sending datagrams to the given address, with the
+// multicast options of the configuration when it is a multicast group.
+func DialUDP(addr netip.AddrPort, cfg UDPConfig) (*net.UDPConn, error) {
+	// Link-local groups can only be reached through a given interface.
+	if addr.Addr().IsLinkLocalMulticast() && addr.Addr().Zone() == "" && cfg.Interface != nil {
+		addr = netip.AddrPortFrom(addr.Addr().WithZone(cfg.Interface.Name), addr.Port())
+	}
+
+	conn, err := net.DialUDP(Network(addr), nil, net.UDPAddrFromAddrPort(addr))
+	if err != nil {
+		return nil, fmt.Errorf("could not dial udp address: %w", err)
+	}
+
+	if addr.Addr().IsMulticast() {
+		if err := setMulticastOptions(conn, addr.Addr().Is6(), cfg); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not set multicast options: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// Network returns the udp network of the given address, udp4 or udp6.
+func Network(addr netip.AddrPort) string {
+	if addr.Addr().Is4() {
+		return "udp4"
+	}
+
+	return "udp6"
+}
+
+func (c *UDPConn) ReadFrom(b []byte) (int, netip.AddrPort, error) {
+	n, from, err := c.listen.ReadFromUDPAddrPort(b)
+	if err != nil {
+		return n, from, err
+	}
+
+	return n, netip.AddrPortFrom(from.Addr().Unmap(), from.Port()), nil
+}
+
+func (c *UDPConn) Write(b []byte) (int, error) {
+	return c.dial.Write(b)
+}
+
+func (c *UDPConn) Close() error {
+	c.dial.Close()
+	return c.listen.Close()
+}